@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docopt/docopt-go"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/IntelAI/nodus/pkg/clean"
+	"github.com/IntelAI/nodus/pkg/client"
+)
+
+func main() {
+	usage := `npclean - Remove Objects Left Behind by nptest and npsim.
+
+Usage:
+  npclean [--run=<id>] [--older-than=<duration>] [--namespace=<ns>] [--all]
+    [--dry-run] [--master=<url> | --kubeconfig=<kconfig>] [--verbose]
+  npclean -h | --help
+
+Options:
+  -h --help                Show this screen.
+  --run=<id>               Only clean up the objects of this run (the np.run
+                           label, logged at the start of every nptest run).
+  --older-than=<duration>  Only clean up objects created at least this long
+                           ago, e.g. 1h.
+  --namespace=<ns>         Only clean up objects in this namespace, and the
+                           fake nodes of the runs that left objects in it.
+  --all                    Also clean up fake nodes that are still alive (a
+                           fresh Ready heartbeat or Lease), the pods bound to
+                           them, and npsim nodes, which have no run.
+  --dry-run                Report what would be deleted, without deleting it.
+  --master=<url>           Kubernetes API server URL.
+  --kubeconfig=<config>    Kubernetes client config file [default: kconfig].
+  --verbose                Enable debug logs.`
+
+	args, _ := docopt.ParseDoc(usage)
+
+	verbose, _ := args.Bool("--verbose")
+	if verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	filter := clean.Filter{}
+	filter.RunID, _ = args.String("--run")
+	filter.Namespace, _ = args.String("--namespace")
+	if olderThan, _ := args.String("--older-than"); olderThan != "" {
+		age, err := time.ParseDuration(olderThan)
+		if err != nil {
+			log.WithFields(log.Fields{"age": olderThan}).Error("age must be a duration, e.g. 1h")
+			os.Exit(1)
+		}
+		filter.OlderThan = age
+	}
+	filter.All, _ = args.Bool("--all")
+	if filter.RunID == "" && filter.Namespace == "" && filter.OlderThan == 0 {
+		log.Warning("no --run, --namespace or --older-than given, cleaning up the objects of every run")
+	}
+
+	// Construct apiserver client
+	master, _ := args.String("--master")
+	kubeconfigPath, _ := args.String("--kubeconfig")
+	if master != "" {
+		kubeconfigPath = ""
+	}
+	k8sclient, err := client.NewK8sClient(master, kubeconfigPath)
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to construct kubernetes client")
+		os.Exit(1)
+	}
+
+	dynamicClient, err := client.NewDynamicClient(master, kubeconfigPath)
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to construct kubernetes dynamic client")
+		os.Exit(1)
+	}
+
+	cleaner := clean.NewCleaner(k8sclient, dynamicClient, filter)
+	objects, err := cleaner.Find()
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to find leftover objects")
+		os.Exit(1)
+	}
+	if len(objects) == 0 {
+		log.Info("nothing to clean up")
+		return
+	}
+	report(objects)
+
+	if dryRun, _ := args.Bool("--dry-run"); dryRun {
+		log.WithFields(log.Fields{"objects": len(objects)}).Info("dry run, nothing deleted")
+		return
+	}
+	if err := cleaner.Delete(objects); err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to clean up")
+		os.Exit(1)
+	}
+	log.WithFields(log.Fields{"objects": len(objects)}).Info("cleaned up")
+}
+
+func report(objects []clean.Object) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tRUN\tAGE\tFORCE")
+	now := time.Now()
+	for _, o := range objects {
+		run := o.Run()
+		if run == "" {
+			run = "-"
+		}
+		force := ""
+		if o.Orphaned {
+			force = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", o.Kind, o.Namespace, o.Name, run, now.Sub(o.Created).Round(time.Second), force)
+	}
+	w.Flush()
+}
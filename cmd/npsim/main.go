@@ -5,10 +5,10 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/docopt/docopt-go"
 	log "github.com/sirupsen/logrus"
-	"k8s.io/client-go/kubernetes"
 
 	"github.com/IntelAI/nodus/pkg/client"
 	"github.com/IntelAI/nodus/pkg/config"
@@ -20,7 +20,8 @@ func main() {
 
 Usage:
   npsim --nodes=<config> [--master=<url> | --kubeconfig=<kconfig>]
-		[--verbose]
+		[--status-interval=<duration>] [--lease-interval=<duration>]
+		[--lease-duration=<duration>] [--startup-latency=<duration>] [--verbose]
   npsim -h | --help
 
 Options:
@@ -28,6 +29,18 @@ Options:
   --nodes=<config>       Nodes config file.
   --master=<url>         Kubernetes API server URL.
   --kubeconfig=<config>  Kubernetes client config file [default: kconfig].
+  --status-interval=<duration>
+                         How often nodes update the heartbeat of their Ready
+                         condition, 0 to disable [default: 10s].
+  --lease-interval=<duration>
+                         How often nodes renew their Lease in kube-node-lease,
+                         0 to disable [default: 10s].
+  --lease-duration=<duration>
+                         Duration of the node Leases [default: 40s].
+  --startup-latency=<duration>
+                         How long pods stay Pending once they are bound, for
+                         node classes without a startupLatency and pods
+                         without an np.startupLatency label [default: 0s].
   --verbose              Enable debug logs.`
 
 	args, _ := docopt.ParseDoc(usage)
@@ -46,6 +59,23 @@ Options:
 	conf, _ := nodeConfig.AsYaml()
 	log.Debugf("using node config:\n%s", conf)
 
+	heartbeat := node.Heartbeat{}
+	var startupLatency time.Duration
+	for flag, interval := range map[string]*time.Duration{
+		"--status-interval": &heartbeat.StatusInterval,
+		"--lease-interval":  &heartbeat.LeaseInterval,
+		"--lease-duration":  &heartbeat.LeaseDuration,
+		"--startup-latency": &startupLatency,
+	} {
+		value, _ := args.String(flag)
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.WithFields(log.Fields{"flag": flag, "value": value}).Error("expected a duration, e.g. 10s")
+			os.Exit(1)
+		}
+		*interval = d
+	}
+
 	// Construct apiserver client
 	master, _ := args.String("--master")
 	kubeconfigPath, _ := args.String("--kubeconfig")
@@ -61,39 +91,45 @@ Options:
 
 	log.Info("Creating nodes...")
 
-	nodes := makeNodes(nodeConfig)
-	err = start(nodes, client)
+	manager := node.NewNodeManager(client)
+	nodes := makeNodes(nodeConfig, heartbeat, startupLatency)
+	err = start(manager, nodes)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to start nodes")
+		manager.Stop()
 		os.Exit(1)
 	}
 
-	defer stop(nodes)
+	defer manager.Stop()
 
 	log.Infof("Registered %d fake nodes", len(nodes))
 	log.Info("Waiting for shutdown signal")
 	<-shutdown
 	fmt.Println("")
-	log.Info("Shutting down (deleting fake nodes)")
+	stats := manager.WatchStats()
+	log.WithFields(log.Fields{"reconnects": stats.Reconnects, "relists": stats.Relists}).Info("Shutting down (deleting fake nodes)")
 }
 
-func makeNodes(nodeConfig *config.NodeConfig) []node.FakeNode {
+func makeNodes(nodeConfig *config.NodeConfig, heartbeat node.Heartbeat, startupLatency time.Duration) []node.FakeNode {
 	nodes := []node.FakeNode{}
 	for _, class := range nodeConfig.NodeClasses {
 		log.WithFields(log.Fields{"class": class.Name}).Debug("making node class")
 		for i := uint(0); i < class.Count; i++ {
 			log.WithFields(log.Fields{"class": class.Name, "id": i}).Debug("making node")
 			name := fmt.Sprintf("%s-%d", class.Name, i)
-			n := node.NewFakeNode(name, class.Name, class.Labels, class.Resources)
+			n := node.NewFakeNode(name, class.Name, class.Labels, class.Resources, heartbeat, class.StartupLatencyOr(startupLatency))
 			nodes = append(nodes, n)
 		}
 	}
 	return nodes
 }
 
-func start(nodes []node.FakeNode, client *kubernetes.Clientset) (err error) {
+func start(manager node.NodeManager, nodes []node.FakeNode) (err error) {
+	if err = manager.Start(); err != nil {
+		return
+	}
 	for _, n := range nodes {
-		if err = n.Start(client); err != nil {
+		if err = manager.Add(n); err != nil {
 			log.WithFields(log.Fields{
 				"node":  n.Name(),
 				"error": err.Error(),
@@ -106,12 +142,3 @@ func start(nodes []node.FakeNode, client *kubernetes.Clientset) (err error) {
 	}
 	return nil
 }
-
-func stop(nodes []node.FakeNode) {
-	for _, n := range nodes {
-		log.WithFields(log.Fields{
-			"node": n.Name(),
-		}).Debug("stopping node")
-		n.Stop()
-	}
-}
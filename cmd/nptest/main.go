@@ -1,12 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/IntelAI/nodus/pkg/client"
 	"github.com/IntelAI/nodus/pkg/config"
 	"github.com/IntelAI/nodus/pkg/dynamic"
 	"github.com/IntelAI/nodus/pkg/exec"
+	"github.com/IntelAI/nodus/pkg/report"
 	"github.com/docopt/docopt-go"
 	log "github.com/sirupsen/logrus"
 )
@@ -15,7 +20,11 @@ func main() {
 	usage := `nptest - Test Kubernetes Scheduling Scenarios.
 
 Usage:
-  nptest --scenario=<config> [--pods=<config>] [--nodes=<config>] [--namespace=<ns>]
+  nptest --scenario=<config> [--pods=<config>] [--nodes=<config>]
+    [--namespace=<ns> | --fresh-namespace] [--delete-namespace]
+    [--set=<param>...] [--continue-on-failure] [--junit=<file>] [--html=<file>]
+    [--metrics=<file>] [--utilization=<file>] [--utilization-interval=<duration>]
+    [--artifacts-dir=<dir>] [--lint]
     [--master=<url> | --kubeconfig=<kconfig>] [--verbose]
   nptest -h | --help
 
@@ -24,8 +33,27 @@ Options:
   --scenario=<config>    Test scenario config file.
   --pods=<config>        Test pod config file.
   --nodes=<config>       Nodes config file.
+  --set=<param>          Override a scenario parameter, e.g. --set nodes=1000.
+  --continue-on-failure  Record failed asserts and keep running the scenario.
+  --junit=<file>         Write the step results as JUnit XML.
+  --html=<file>          Write an HTML report with a pod timeline, node
+                         utilization and summary statistics.
+  --metrics=<file>       Write scheduling latency and throughput as JSON.
+  --utilization=<file>   Write the node utilization timeline, as CSV if the
+                         file ends in .csv and as JSON otherwise.
+  --utilization-interval=<duration>
+                         How often to sample node utilization [default: 1s].
+  --artifacts-dir=<dir>  Where to write the cluster state when an assert
+                         fails, one directory per step. Nothing is written
+                         without it.
+  --lint                 Check the scenario against the node and pod configs,
+                         without contacting the API server, and exit.
   --namespace=<ns>       Namespace to use for tests (will be created if
 	                       it does not exist) [default: default]
+  --fresh-namespace      Create a namespace with a unique name for this run,
+                         and delete it at the end.
+  --delete-namespace     Delete the namespace at the end of the run, if
+                         nptest created it.
   --master=<url>         Kubernetes API server URL.
   --kubeconfig=<config>  Kubernetes client config file [default: kconfig].
   --verbose              Enable debug logs.`
@@ -37,13 +65,28 @@ Options:
 		log.SetLevel(log.DebugLevel)
 	}
 
+	params := map[string]string{}
+	sets, _ := args["--set"].([]string)
+	for _, set := range sets {
+		kv := strings.SplitN(set, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			log.WithFields(log.Fields{"param": set}).Error("parameters must be set as <name>=<value>")
+			os.Exit(1)
+		}
+		params[kv[0]] = kv[1]
+	}
+
 	scenarioConfigPath, _ := args.String("--scenario")
-	scenario, err := config.ScenarioFromFile(scenarioConfigPath)
+	scenario, err := config.ScenarioFromFileWithParams(scenarioConfigPath, params)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to read scenario config")
 		os.Exit(1)
 	}
 
+	if continueOnFailure, _ := args.Bool("--continue-on-failure"); continueOnFailure {
+		scenario.ContinueOnFailure = true
+	}
+
 	podConfigPath, _ := args.String("--pods")
 	var podConfig *config.PodConfig
 	if podConfigPath != "" {
@@ -64,6 +107,19 @@ Options:
 		}
 	}
 
+	if lint, _ := args.Bool("--lint"); lint {
+		problems := config.Validate(scenario, nodeConfig, podConfig)
+		for _, problem := range problems {
+			log.WithFields(log.Fields{"problem": problem.Error()}).Error("invalid scenario")
+		}
+		if len(problems) > 0 {
+			log.WithFields(log.Fields{"problems": len(problems)}).Error("scenario failed validation")
+			os.Exit(1)
+		}
+		log.WithFields(log.Fields{"name": scenario.Name}).Info("scenario is valid")
+		os.Exit(0)
+	}
+
 	// Construct apiserver client
 	master, _ := args.String("--master")
 	kubeconfigPath, _ := args.String("--kubeconfig")
@@ -82,14 +138,98 @@ Options:
 		os.Exit(1)
 	}
 
+	utilizationInterval, _ := args.String("--utilization-interval")
+	sampleInterval, err := time.ParseDuration(utilizationInterval)
+	if err != nil || sampleInterval <= 0 {
+		log.WithFields(log.Fields{"interval": utilizationInterval}).Error("utilization interval must be a positive duration, e.g. 500ms")
+		os.Exit(1)
+	}
+
 	// construct scenario runner
 	namespace, _ := args.String("--namespace")
+	deleteNamespace, _ := args.Bool("--delete-namespace")
+	if fresh, _ := args.Bool("--fresh-namespace"); fresh {
+		namespace = exec.FreshNamespace()
+		deleteNamespace = true
+		log.WithFields(log.Fields{"namespace": namespace}).Info("using a fresh namespace")
+	}
 
 	dynamicClient := dynamic.NewDynamicClient(dynamicClientSet, k8sclient, namespace)
 	runner := exec.NewScenarioRunner(k8sclient, namespace, nodeConfig, podConfig, dynamicClient)
-	err = runner.RunScenario(scenario)
+	runner.SetUtilizationInterval(sampleInterval)
+	runner.SetDeleteNamespace(deleteNamespace)
+	artifactsDir, _ := args.String("--artifacts-dir")
+	runner.SetArtifactsDir(artifactsDir)
+	result, err := runner.RunScenario(scenario)
+	logResult(result)
+	if junitPath, _ := args.String("--junit"); junitPath != "" {
+		if junitErr := report.WriteJUnitFile(junitPath, result); junitErr != nil {
+			log.WithFields(log.Fields{"error": junitErr.Error()}).Error("failed to write junit report")
+			os.Exit(1)
+		}
+	}
+	if metricsPath, _ := args.String("--metrics"); metricsPath != "" {
+		if metricsErr := report.WriteMetricsFile(metricsPath, result); metricsErr != nil {
+			log.WithFields(log.Fields{"error": metricsErr.Error()}).Error("failed to write metrics")
+			os.Exit(1)
+		}
+	}
+	if utilizationPath, _ := args.String("--utilization"); utilizationPath != "" {
+		if utilizationErr := report.WriteUtilizationFile(utilizationPath, result); utilizationErr != nil {
+			log.WithFields(log.Fields{"error": utilizationErr.Error()}).Error("failed to write utilization")
+			os.Exit(1)
+		}
+	}
+	if htmlPath, _ := args.String("--html"); htmlPath != "" {
+		if htmlErr := report.WriteHTMLFile(htmlPath, result); htmlErr != nil {
+			log.WithFields(log.Fields{"error": htmlErr.Error()}).Error("failed to write html report")
+			os.Exit(1)
+		}
+	}
 	if err != nil {
 		log.WithFields(log.Fields{"error": err.Error()}).Error("failed to complete scenario")
 		os.Exit(1)
 	}
 }
+
+func logResult(result *exec.ScenarioResult) {
+	counts := map[exec.StepStatus]int{}
+	for _, step := range result.Steps {
+		counts[step.Status]++
+	}
+	for _, step := range result.Failures() {
+		log.WithFields(log.Fields{
+			"description": step.Description,
+			"error":       step.Err.Error(),
+		}).Errorf("%s failed", step.Name())
+	}
+	if m := result.Metrics; m != nil && m.PodsCreated > 0 {
+		log.WithFields(log.Fields{
+			"created":    m.PodsCreated,
+			"scheduled":  m.PodsScheduled,
+			"running":    m.PodsRunning,
+			"throughput": fmt.Sprintf("%.2f pods/s", m.Throughput),
+			"peak":       fmt.Sprintf("%.0f pods/s", m.PeakThroughput),
+		}).Info("binding throughput")
+		log.WithFields(log.Fields{"latency": m.SchedulingLatency}).Info("scheduling latency")
+		log.WithFields(log.Fields{"latency": m.TimeToRunning}).Info("time to running")
+		classes := []string{}
+		for class := range m.Classes {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			log.WithFields(log.Fields{
+				"class":      class,
+				"scheduling": m.Classes[class].SchedulingLatency,
+				"running":    m.Classes[class].TimeToRunning,
+			}).Info("latency by pod class")
+		}
+	}
+	log.WithFields(log.Fields{
+		"passed":   counts[exec.StepPassed],
+		"failed":   counts[exec.StepFailed],
+		"skipped":  counts[exec.StepSkipped],
+		"duration": result.Duration,
+	}).Info("scenario result")
+}
@@ -0,0 +1,391 @@
+package clean
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+	"github.com/IntelAI/nodus/pkg/node"
+)
+
+// Kinds of objects left behind by nodus. Objects created from yaml, such as
+// deployments and jobs, keep their own kind.
+const (
+	Pod       = "Pod"
+	Node      = "Node"
+	Namespace = "Namespace"
+)
+
+// How long after its last heartbeat a node without a Lease still counts as
+// alive, the node controller's default grace period.
+const heartbeatGracePeriod = 40 * time.Second
+
+// The order in which objects are deleted: objects created from yaml first,
+// so that controllers do not recreate their pods, and nodes after the pods
+// bound to them.
+var deleteOrder = map[string]int{Pod: 1, Node: 2, Namespace: 3}
+
+// Object is something nptest or npsim left in the cluster.
+type Object struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Labels    map[string]string
+	Created   time.Time
+	// Pods only: bound to a node that is cleaned up or already gone, so no
+	// kubelet will finalize the pod and it is deleted without a grace period
+	Orphaned   bool
+	Finalizers []string
+	// The resource of objects created from yaml
+	resource schema.GroupVersionResource
+}
+
+func (o Object) Run() string {
+	return o.Labels[exec.RunLabel]
+}
+
+func (o Object) String() string {
+	if o.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", strings.ToLower(o.Kind), o.Namespace, o.Name)
+	}
+	return fmt.Sprintf("%s %s", strings.ToLower(o.Kind), o.Name)
+}
+
+// Filter selects the objects to clean up. The zero value selects everything.
+type Filter struct {
+	// Only objects of this run
+	RunID string
+	// Only objects created at least this long ago
+	OlderThan time.Duration
+	// Only objects in this namespace, and the nodes of the runs that left
+	// objects in it
+	Namespace string
+	// Also fake nodes that are still alive, i.e. whose Ready heartbeat or
+	// Lease is fresh, and the pods bound to them. Otherwise only nodes of a
+	// run are cleaned up, or, with OlderThan, also the nodes of npsim, which
+	// have no run.
+	All bool
+}
+
+func (f Filter) Matches(o Object, now time.Time) bool {
+	if f.RunID != "" && o.Run() != f.RunID {
+		return false
+	}
+	if f.OlderThan > 0 && now.Sub(o.Created) < f.OlderThan {
+		return false
+	}
+	return true
+}
+
+type Cleaner struct {
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
+	filter        Filter
+	now           func() time.Time
+}
+
+func NewCleaner(client kubernetes.Interface, dynamicClient dynamic.Interface, filter Filter) *Cleaner {
+	return &Cleaner{client: client, dynamicClient: dynamicClient, filter: filter, now: time.Now}
+}
+
+// Find lists the objects carrying nodus labels that match the filter: fake
+// nodes (np.class), the namespaces nptest created (np.owner), namespaced
+// objects of any kind created from yaml (np.run), pods of a class (np.class),
+// and any pod bound to a fake node that is cleaned up or already gone.
+//
+// With a namespace filter, only the fake nodes of the runs that left objects
+// in the namespace are cleaned up, since other runs, and npsim, may still be
+// using the rest. Unless the filter selects all nodes, nodes that are still
+// alive are left alone, as are the pods bound to them.
+func (c *Cleaner) Find() ([]Object, error) {
+	now := c.now()
+	objects := []Object{}
+	// Runs that left objects in the filtered namespace
+	runs := map[string]bool{}
+
+	namespaceList, err := c.client.CoreV1().Namespaces().List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", exec.OwnerLabel, exec.OwnerValue),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %s", err.Error())
+	}
+	for _, ns := range namespaceList.Items {
+		o := objectOf(Namespace, ns.ObjectMeta)
+		if c.filter.Namespace != "" && ns.Name != c.filter.Namespace {
+			continue
+		}
+		runs[o.Run()] = true
+		if c.filter.Matches(o, now) {
+			objects = append(objects, o)
+		}
+	}
+
+	created, err := c.findCreated()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range created {
+		runs[o.Run()] = true
+		if c.filter.Matches(o, now) {
+			objects = append(objects, o)
+		}
+	}
+
+	podList, err := c.client.CoreV1().Pods(c.filter.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %s", err.Error())
+	}
+	for _, pod := range podList.Items {
+		runs[pod.Labels[exec.RunLabel]] = true
+	}
+	delete(runs, "")
+
+	nodeList, err := c.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %s", err.Error())
+	}
+	leases, err := c.leases()
+	if err != nil {
+		return nil, err
+	}
+	existingNodes, cleanedNodes, liveNodes := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, n := range nodeList.Items {
+		existingNodes[n.Name] = true
+		if _, fake := n.Labels[node.NodeClassLabel]; !fake {
+			continue
+		}
+		o := objectOf(Node, n.ObjectMeta)
+		if !c.filter.All {
+			if alive(n, leases[n.Name], now) {
+				liveNodes[n.Name] = true
+				log.WithFields(log.Fields{"node": n.Name}).Debug("skipping live node")
+				continue
+			}
+			if o.Run() == "" && c.filter.OlderThan == 0 {
+				log.WithFields(log.Fields{"node": n.Name}).Debug("skipping node without a run")
+				continue
+			}
+		}
+		if c.filter.Namespace != "" && !runs[o.Run()] {
+			continue
+		}
+		if c.filter.Matches(o, now) {
+			cleanedNodes[n.Name] = true
+			objects = append(objects, o)
+		}
+	}
+
+	for _, pod := range podList.Items {
+		if liveNodes[pod.Spec.NodeName] {
+			continue
+		}
+		if o, ok := podObject(pod, existingNodes, cleanedNodes, c.filter, now); ok {
+			objects = append(objects, o)
+		}
+	}
+
+	sortObjects(objects)
+	return objects, nil
+}
+
+// Returns the node leases by node name, or none if the API server does not
+// serve them.
+func (c *Cleaner) leases() (map[string]coordinationv1beta1.Lease, error) {
+	leases := map[string]coordinationv1beta1.Lease{}
+	leaseList, err := c.client.CoordinationV1beta1().Leases(corev1.NamespaceNodeLease).List(metav1.ListOptions{})
+	if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+		return leases, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node leases: %s", err.Error())
+	}
+	for _, lease := range leaseList.Items {
+		leases[lease.Name] = lease
+	}
+	return leases, nil
+}
+
+// Whether the node's Lease has not expired, or its Ready heartbeat is within
+// the grace period.
+func alive(n corev1.Node, lease coordinationv1beta1.Lease, now time.Time) bool {
+	if renewed := lease.Spec.RenewTime; renewed != nil && lease.Spec.LeaseDurationSeconds != nil {
+		if now.Before(renewed.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)) {
+			return true
+		}
+	}
+	for _, cond := range n.Status.Conditions {
+		if cond.Type == corev1.NodeReady && now.Sub(cond.LastHeartbeatTime.Time) < heartbeatGracePeriod {
+			return true
+		}
+	}
+	return false
+}
+
+// Lists the namespaced objects of every kind that carry the np.run label,
+// except pods, which are found by their class, and objects owned by a
+// controller, which are deleted along with it.
+func (c *Cleaner) findCreated() ([]Object, error) {
+	resourceLists, err := discovery.ServerPreferredNamespacedResources(c.client.Discovery())
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, fmt.Errorf("failed to discover resources: %s", err.Error())
+		}
+		// Aggregated APIs that are down; the other groups are complete
+		log.WithFields(log.Fields{"error": err.Error()}).Warning("failed to discover some resources")
+	}
+
+	objects := []Object{}
+	runSelector := metav1.ListOptions{LabelSelector: exec.RunLabel}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range resourceList.APIResources {
+			if !listable(r) || (gv.Group == "" && r.Kind == Pod) {
+				continue
+			}
+			resource := gv.WithResource(r.Name)
+			list, err := c.dynamicClient.Resource(resource).Namespace(c.filter.Namespace).List(runSelector)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %s", r.Name, err.Error())
+			}
+			for _, item := range list.Items {
+				if metav1.GetControllerOf(&item) != nil {
+					continue
+				}
+				o := Object{
+					Kind:       r.Kind,
+					Namespace:  item.GetNamespace(),
+					Name:       item.GetName(),
+					Labels:     item.GetLabels(),
+					Created:    item.GetCreationTimestamp().Time,
+					Finalizers: item.GetFinalizers(),
+					resource:   resource,
+				}
+				objects = append(objects, o)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// Whether the resource can be listed and deleted, and is not a subresource.
+func listable(r metav1.APIResource) bool {
+	if strings.Contains(r.Name, "/") {
+		return false
+	}
+	verbs := sets.NewString(r.Verbs...)
+	return verbs.Has("list") && verbs.Has("delete")
+}
+
+// Returns the pod if it is to be cleaned up: it has a class and matches the
+// filter, or it is bound to a fake node that is cleaned up. Pods bound to
+// cleaned up nodes, or to nodes that no longer exist, are orphaned.
+func podObject(pod corev1.Pod, existingNodes, cleanedNodes map[string]bool, filter Filter, now time.Time) (Object, bool) {
+	o := objectOf(Pod, pod.ObjectMeta)
+	nodeName := pod.Spec.NodeName
+	o.Orphaned = nodeName != "" && (cleanedNodes[nodeName] || !existingNodes[nodeName])
+
+	_, hasClass := pod.Labels[node.NodeClassLabel]
+	return o, cleanedNodes[nodeName] || (hasClass && filter.Matches(o, now))
+}
+
+func objectOf(kind string, meta metav1.ObjectMeta) Object {
+	return Object{
+		Kind:       kind,
+		Namespace:  meta.Namespace,
+		Name:       meta.Name,
+		Labels:     meta.Labels,
+		Created:    meta.CreationTimestamp.Time,
+		Finalizers: meta.Finalizers,
+	}
+}
+
+func sortObjects(objects []Object) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if deleteOrder[a.Kind] != deleteOrder[b.Kind] {
+			return deleteOrder[a.Kind] < deleteOrder[b.Kind]
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Delete deletes the objects in order, going on after failures, and returns
+// an error listing the objects it failed to delete. Objects that are already
+// gone are not failures.
+func (c *Cleaner) Delete(objects []Object) error {
+	failures := []string{}
+	for _, o := range objects {
+		err := c.delete(o)
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.WithFields(log.Fields{"object": o.String(), "error": err.Error()}).Error("failed to delete")
+			failures = append(failures, fmt.Sprintf("%s: %s", o, err.Error()))
+			continue
+		}
+		log.WithFields(log.Fields{"object": o.String()}).Info("deleted")
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to delete %d objects: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (c *Cleaner) delete(o Object) error {
+	background := metav1.DeletePropagationBackground
+	options := &metav1.DeleteOptions{PropagationPolicy: &background}
+	if !o.resource.Empty() {
+		return c.dynamicClient.Resource(o.resource).Namespace(o.Namespace).Delete(o.Name, options)
+	}
+	switch o.Kind {
+	case Pod:
+		return c.deletePod(o)
+	case Node:
+		return c.client.CoreV1().Nodes().Delete(o.Name, options)
+	case Namespace:
+		return c.client.CoreV1().Namespaces().Delete(o.Name, options)
+	}
+	return fmt.Errorf("unknown kind %s", o.Kind)
+}
+
+// Deletes the pod, finalizing it first if it is orphaned: no kubelet will
+// confirm the deletion, so finalizers are removed and the pod is deleted
+// without a grace period.
+func (c *Cleaner) deletePod(o Object) error {
+	pods := c.client.CoreV1().Pods(o.Namespace)
+	if !o.Orphaned {
+		return pods.Delete(o.Name, &metav1.DeleteOptions{})
+	}
+	if len(o.Finalizers) > 0 {
+		pod, err := pods.Get(o.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		pod.Finalizers = nil
+		if _, err := pods.Update(pod); err != nil {
+			return fmt.Errorf("failed to remove finalizers: %s", err.Error())
+		}
+	}
+	var immediately int64
+	return pods.Delete(o.Name, &metav1.DeleteOptions{GracePeriodSeconds: &immediately})
+}
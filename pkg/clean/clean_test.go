@@ -0,0 +1,264 @@
+package clean
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+	"github.com/IntelAI/nodus/pkg/node"
+)
+
+func Test_podObject(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	pod := func(labels map[string]string, node string, age time.Duration) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              "p",
+				Labels:            labels,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+		}
+	}
+	existing := map[string]bool{"fake-1": true, "fake-2": true, "real": true}
+	cleaned := map[string]bool{"fake-1": true}
+	class := map[string]string{"np.class": "1-cpu", "np.run": "run-1"}
+
+	cases := []struct {
+		desc     string
+		pod      corev1.Pod
+		filter   Filter
+		found    bool
+		orphaned bool
+	}{
+		{desc: "unbound pod of a class", pod: pod(class, "", time.Hour), found: true},
+		{desc: "pod of a class on a live fake node", pod: pod(class, "fake-2", time.Hour), found: true},
+		{desc: "pod of a class on a node that is gone", pod: pod(class, "gone", time.Hour), found: true, orphaned: true},
+		{desc: "pod without a class on a cleaned node", pod: pod(nil, "fake-1", time.Hour), found: true, orphaned: true},
+		{desc: "pod without a class elsewhere", pod: pod(nil, "real", time.Hour), found: false},
+		{desc: "pod of another run", pod: pod(class, "", time.Hour), filter: Filter{RunID: "run-2"}, found: false},
+		{desc: "pod of the run", pod: pod(class, "", time.Hour), filter: Filter{RunID: "run-1"}, found: true},
+		{desc: "pod too young", pod: pod(class, "real", time.Minute), filter: Filter{OlderThan: time.Hour}, found: false},
+		{desc: "pod old enough", pod: pod(class, "real", 2*time.Hour), filter: Filter{OlderThan: time.Hour}, found: true},
+		{desc: "young pod on a cleaned node", pod: pod(class, "fake-1", time.Minute), filter: Filter{OlderThan: time.Hour}, found: true, orphaned: true},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		o, found := podObject(c.pod, existing, cleaned, c.filter, now)
+		if found != c.found {
+			t.Fatalf("(case: %s) expected found %t, got %t", c.desc, c.found, found)
+		}
+		if o.Orphaned != c.orphaned {
+			t.Fatalf("(case: %s) expected orphaned %t, got %t", c.desc, c.orphaned, o.Orphaned)
+		}
+	}
+}
+
+func Test_sortObjects(t *testing.T) {
+	objects := []Object{
+		{Kind: Namespace, Name: "nptest-a"},
+		{Kind: Node, Name: "large-b"},
+		{Kind: Pod, Namespace: "b", Name: "p"},
+		{Kind: Node, Name: "large-a"},
+		{Kind: Pod, Namespace: "a", Name: "q"},
+		{Kind: "Job", Namespace: "a", Name: "j"},
+		{Kind: "Deployment", Namespace: "a", Name: "d"},
+	}
+	expected := []string{
+		"deployment a/d",
+		"job a/j",
+		"pod a/q",
+		"pod b/p",
+		"node large-a",
+		"node large-b",
+		"namespace nptest-a",
+	}
+	sortObjects(objects)
+	actual := []string{}
+	for _, o := range objects {
+		actual = append(actual, o.String())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestCleaner_Find(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	meta := func(namespace, name string, labels map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+		}
+	}
+	run := func(id string) map[string]string {
+		return map[string]string{exec.RunLabel: id}
+	}
+	class := func(id string) map[string]string {
+		return map[string]string{node.NodeClassLabel: "large", exec.RunLabel: id}
+	}
+	object := func(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		u.SetLabels(labels)
+		return u
+	}
+
+	heartbeat := func(at time.Time) corev1.NodeStatus {
+		return corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastHeartbeatTime: metav1.NewTime(at)},
+		}}
+	}
+	renewed := metav1.NewMicroTime(now.Add(-10 * time.Second))
+	leaseDuration := int32(40)
+
+	// Two runs in their own namespaces, npsim nodes without a run, and a
+	// run whose nodes are still alive
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: meta("", "ns-a", map[string]string{exec.OwnerLabel: exec.OwnerValue, exec.RunLabel: "run-a"})},
+		&corev1.Namespace{ObjectMeta: meta("", "ns-b", map[string]string{exec.OwnerLabel: exec.OwnerValue, exec.RunLabel: "run-b"})},
+		&corev1.Node{ObjectMeta: meta("", "large-a", class("run-a"))},
+		&corev1.Node{ObjectMeta: meta("", "large-b", class("run-b"))},
+		&corev1.Node{ObjectMeta: meta("", "large-0", map[string]string{node.NodeClassLabel: "large"})},
+		&corev1.Pod{ObjectMeta: meta("ns-a", "pod-a", class("run-a")), Spec: corev1.PodSpec{NodeName: "large-a"}},
+		&corev1.Pod{ObjectMeta: meta("ns-b", "pod-b", class("run-b")), Spec: corev1.PodSpec{NodeName: "large-b"}},
+		&corev1.Node{ObjectMeta: meta("", "large-c", class("run-c")), Status: heartbeat(now.Add(-10 * time.Second))},
+		&corev1.Node{ObjectMeta: meta("", "large-d", class("run-c")), Status: heartbeat(now.Add(-time.Hour))},
+		&corev1.Pod{ObjectMeta: meta("default", "pod-c", class("run-c")), Spec: corev1.PodSpec{NodeName: "large-c"}},
+		&coordinationv1beta1.Lease{
+			ObjectMeta: meta(corev1.NamespaceNodeLease, "large-d", nil),
+			Spec:       coordinationv1beta1.LeaseSpec{RenewTime: &renewed, LeaseDurationSeconds: &leaseDuration},
+		},
+	}
+	created := []runtime.Object{
+		object("apps/v1", "StatefulSet", "ns-a", "web-x7k2p", run("run-a")),
+		object("v1", "ConfigMap", "ns-a", "config-b4d9q", run("run-a")),
+		object("v1", "ConfigMap", "ns-a", "unlabeled", nil),
+		object("apps/v1", "StatefulSet", "ns-b", "web-q2zt8", run("run-b")),
+	}
+	resources := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "pods/status", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "nodes", Kind: "Node", Verbs: []string{"list", "delete"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true, Verbs: []string{"list", "delete"}},
+		}},
+	}
+
+	cases := []struct {
+		desc     string
+		filter   Filter
+		expected []string
+	}{
+		{
+			desc:   "every run, except live nodes and nodes without a run",
+			filter: Filter{},
+			expected: []string{
+				"configmap ns-a/config-b4d9q",
+				"statefulset ns-a/web-x7k2p",
+				"statefulset ns-b/web-q2zt8",
+				"pod ns-a/pod-a",
+				"pod ns-b/pod-b",
+				"node large-a",
+				"node large-b",
+				"namespace ns-a",
+				"namespace ns-b",
+			},
+		},
+		{
+			desc:   "old enough, including nodes without a run",
+			filter: Filter{OlderThan: time.Minute},
+			expected: []string{
+				"configmap ns-a/config-b4d9q",
+				"statefulset ns-a/web-x7k2p",
+				"statefulset ns-b/web-q2zt8",
+				"pod ns-a/pod-a",
+				"pod ns-b/pod-b",
+				"node large-0",
+				"node large-a",
+				"node large-b",
+				"namespace ns-a",
+				"namespace ns-b",
+			},
+		},
+		{
+			desc:   "everything",
+			filter: Filter{All: true},
+			expected: []string{
+				"configmap ns-a/config-b4d9q",
+				"statefulset ns-a/web-x7k2p",
+				"statefulset ns-b/web-q2zt8",
+				"pod default/pod-c",
+				"pod ns-a/pod-a",
+				"pod ns-b/pod-b",
+				"node large-0",
+				"node large-a",
+				"node large-b",
+				"node large-c",
+				"node large-d",
+				"namespace ns-a",
+				"namespace ns-b",
+			},
+		},
+		{
+			desc:   "a namespace and the nodes of its run",
+			filter: Filter{Namespace: "ns-a"},
+			expected: []string{
+				"configmap ns-a/config-b4d9q",
+				"statefulset ns-a/web-x7k2p",
+				"pod ns-a/pod-a",
+				"node large-a",
+				"namespace ns-a",
+			},
+		},
+		{
+			desc:   "a run",
+			filter: Filter{RunID: "run-b"},
+			expected: []string{
+				"statefulset ns-b/web-q2zt8",
+				"pod ns-b/pod-b",
+				"node large-b",
+				"namespace ns-b",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		client := fake.NewSimpleClientset(objects...)
+		client.Fake.Resources = resources
+		cleaner := NewCleaner(client, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), created...), c.filter)
+		cleaner.now = func() time.Time { return now }
+		found, err := cleaner.Find()
+		if err != nil {
+			t.Fatalf("(case: %s) unexpected error: %s", c.desc, err.Error())
+		}
+		actual := []string{}
+		for _, o := range found {
+			actual = append(actual, o.String())
+		}
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("(case: %s) expected:\n%v\ngot:\n%v", c.desc, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var variableReference = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// Replaces each ${expr} in s. A reference to a single variable is replaced
+// by its value as is; anything else is evaluated as integer arithmetic over
+// the variables, e.g. ${nodes*2}.
+func substitute(s string, vars map[string]string) (string, error) {
+	var err error
+	result := variableReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		expr := strings.TrimSpace(variableReference.FindStringSubmatch(ref)[1])
+		if variableName.MatchString(expr) {
+			value, ok := vars[expr]
+			if !ok {
+				err = fmt.Errorf("undefined variable `%s`", expr)
+			}
+			return value
+		}
+		var value int64
+		value, err = evaluate(expr, vars)
+		if err != nil {
+			err = fmt.Errorf("%s in `${%s}`", err.Error(), expr)
+		}
+		return strconv.FormatInt(value, 10)
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// Evaluates an integer expression of numbers, variables, + - * / %, unary
+// minus and parentheses.
+func evaluate(expr string, vars map[string]string) (int64, error) {
+	e := &evaluator{input: []rune(expr), vars: vars}
+	value, err := e.sum()
+	if err != nil {
+		return 0, err
+	}
+	e.skipSpace()
+	if e.pos < len(e.input) {
+		return 0, fmt.Errorf("unexpected `%c` at column %d", e.input[e.pos], e.pos+1)
+	}
+	return value, nil
+}
+
+type evaluator struct {
+	input []rune
+	pos   int
+	vars  map[string]string
+}
+
+func (e *evaluator) skipSpace() {
+	for e.pos < len(e.input) && unicode.IsSpace(e.input[e.pos]) {
+		e.pos++
+	}
+}
+
+// Returns the next operator if it is one of ops, and consumes it.
+func (e *evaluator) operator(ops string) (rune, bool) {
+	e.skipSpace()
+	if e.pos < len(e.input) && strings.ContainsRune(ops, e.input[e.pos]) {
+		e.pos++
+		return e.input[e.pos-1], true
+	}
+	return 0, false
+}
+
+func (e *evaluator) sum() (int64, error) {
+	value, err := e.product()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := e.operator("+-")
+		if !ok {
+			return value, nil
+		}
+		rhs, err := e.product()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			value += rhs
+		} else {
+			value -= rhs
+		}
+	}
+}
+
+func (e *evaluator) product() (int64, error) {
+	value, err := e.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := e.operator("*/%")
+		if !ok {
+			return value, nil
+		}
+		rhs, err := e.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			value *= rhs
+		case '/', '%':
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			if op == '/' {
+				value /= rhs
+			} else {
+				value %= rhs
+			}
+		}
+	}
+}
+
+func (e *evaluator) unary() (int64, error) {
+	if _, ok := e.operator("-"); ok {
+		value, err := e.unary()
+		return -value, err
+	}
+	return e.operand()
+}
+
+func (e *evaluator) operand() (int64, error) {
+	if _, ok := e.operator("("); ok {
+		value, err := e.sum()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := e.operator(")"); !ok {
+			return 0, fmt.Errorf("missing `)` at column %d", e.pos+1)
+		}
+		return value, nil
+	}
+
+	start := e.pos
+	for e.pos < len(e.input) && (e.input[e.pos] == '_' || unicode.IsLetter(e.input[e.pos]) || unicode.IsDigit(e.input[e.pos])) {
+		e.pos++
+	}
+	token := string(e.input[start:e.pos])
+	switch {
+	case token == "":
+		if e.pos < len(e.input) {
+			return 0, fmt.Errorf("unexpected `%c` at column %d", e.input[e.pos], e.pos+1)
+		}
+		return 0, fmt.Errorf("unexpected end of expression")
+	case unicode.IsDigit(e.input[start]):
+		value, err := strconv.ParseInt(token, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number `%s`", token)
+		}
+		return value, nil
+	}
+	raw, ok := e.vars[token]
+	if !ok {
+		return 0, fmt.Errorf("undefined variable `%s`", token)
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("variable `%s` is not a number: `%s`", token, raw)
+	}
+	return value, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_substitute(t *testing.T) {
+	vars := map[string]string{"nodes": "10", "class": "1-cpu", "zero": "0"}
+
+	cases := []struct {
+		desc     string
+		raw      string
+		expected string
+		err      error
+	}{
+		{
+			desc:     "no references",
+			raw:      "create 1 large node",
+			expected: "create 1 large node",
+		},
+		{
+			desc:     "variables",
+			raw:      "create ${nodes} ${ class } pods",
+			expected: "create 10 1-cpu pods",
+		},
+		{
+			desc:     "arithmetic",
+			raw:      "${nodes*2} ${nodes + 2*3} ${(nodes+2)*3} ${nodes/3} ${nodes%3} ${-nodes+12} ${nodes - -1}",
+			expected: "20 16 36 3 1 2 11",
+		},
+
+		// Negative tests
+		{
+			desc: "undefined variable",
+			raw:  "create ${pods} pods",
+			err:  fmt.Errorf("undefined variable `pods`"),
+		},
+		{
+			desc: "undefined variable in expression",
+			raw:  "create ${pods*2} pods",
+			err:  fmt.Errorf("undefined variable `pods` in `${pods*2}`"),
+		},
+		{
+			desc: "arithmetic on a string",
+			raw:  "${class+1}",
+			err:  fmt.Errorf("variable `class` is not a number: `1-cpu` in `${class+1}`"),
+		},
+		{
+			desc: "division by zero",
+			raw:  "${nodes/zero}",
+			err:  fmt.Errorf("division by zero in `${nodes/zero}`"),
+		},
+		{
+			desc: "missing parenthesis",
+			raw:  "${(nodes+1}",
+			err:  fmt.Errorf("missing `)` at column 9 in `${(nodes+1}`"),
+		},
+		{
+			desc: "trailing operator",
+			raw:  "${nodes*}",
+			err:  fmt.Errorf("unexpected end of expression in `${nodes*}`"),
+		},
+		{
+			desc: "unexpected character",
+			raw:  "${nodes^2}",
+			err:  fmt.Errorf("unexpected `^` at column 6 in `${nodes^2}`"),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+
+		actual, err := substitute(c.raw, vars)
+		if c.err != nil {
+			if err == nil || err.Error() != c.err.Error() {
+				t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(case: %s) expected err to be nil, but got: %s", c.desc, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("(case: %s) expected: `%s`, but got `%s`", c.desc, c.expected, actual)
+		}
+	}
+}
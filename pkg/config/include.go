@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Loads the files a scenario includes, each once, and replaces include steps
+// by the steps they refer to.
+type includeResolver struct {
+	// Scenario and fragment files by path; the scenario itself is at the
+	// path it was loaded from, or "" if it was loaded from bytes
+	files map[string]*ScenarioYaml
+}
+
+func newIncludeResolver(path string, scenario *ScenarioYaml) *includeResolver {
+	return &includeResolver{files: map[string]*ScenarioYaml{path: scenario}}
+}
+
+func (r *includeResolver) load(path string) (*ScenarioYaml, error) {
+	if sy, ok := r.files[path]; ok {
+		return sy, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sy := &ScenarioYaml{}
+	if err := yaml.Unmarshal(data, sy); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	r.files[path] = sy
+	return sy, nil
+}
+
+// Names a file or fragment in include chains.
+func (r *includeResolver) label(path string, fragment string) string {
+	if path == "" {
+		path = "scenario"
+	}
+	if fragment != "" {
+		return path + "#" + fragment
+	}
+	return path
+}
+
+// Returns a copy of rawSteps, which appear in the file at path, with all
+// includes resolved recursively. The chain lists the files and fragments that
+// led to rawSteps, outermost first.
+func (r *includeResolver) resolve(rawSteps []RawStep, path string, chain []string) ([]RawStep, error) {
+	resolved := []RawStep{}
+	for _, raw := range rawSteps {
+		if raw.Block != nil {
+			block := *raw.Block
+			steps, err := r.resolve(block.Steps, path, chain)
+			if err != nil {
+				return nil, err
+			}
+			block.Steps = steps
+			raw.Block = &block
+		}
+		if raw.Include != "" {
+			includedPath, fragment := path, ""
+			parts := strings.SplitN(raw.Include, "#", 2)
+			if parts[0] != "" {
+				includedPath = filepath.Join(filepath.Dir(path), parts[0])
+			}
+			if len(parts) == 2 {
+				fragment = parts[1]
+			}
+
+			link := r.label(includedPath, fragment)
+			for _, c := range chain {
+				if c == link {
+					return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), link)
+				}
+			}
+			included := append(append([]string{}, chain...), link)
+			source := strings.Join(included, " -> ")
+
+			sy, err := r.load(includedPath)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", source, err.Error())
+			}
+			steps := sy.RawSteps
+			if fragment != "" {
+				var ok bool
+				if steps, ok = sy.Fragments[fragment]; !ok {
+					return nil, fmt.Errorf("%s: no fragment `%s` in %s", source, fragment, r.label(includedPath, ""))
+				}
+			}
+			if len(steps) == 0 {
+				return nil, fmt.Errorf("%s: no steps to include", source)
+			}
+			if raw.included, err = r.resolve(steps, includedPath, included); err != nil {
+				return nil, err
+			}
+			raw.source = source
+		}
+		resolved = append(resolved, raw)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "nodus-include")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	return dir
+}
+
+func Test_ScenarioFromFile_includes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"scenario.yml": `
+fragments:
+  cleanup:
+  - "delete 1 large node"
+steps:
+- include: common/setup.yml
+- repeat: 2
+  steps:
+  - include: common/setup.yml#pods
+- include: "#cleanup"
+`,
+		"common/setup.yml": `
+steps:
+- "create 1 large node"
+- include: nodes.yml
+fragments:
+  pods:
+  - "create 1 1-cpu pod"
+`,
+		"common/nodes.yml": `
+steps:
+- "assert 1 large node"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	createPod := func(iteration string) *Step {
+		return &Step{Verb: Create, Create: &CreateStep{Count: 1, Class: Class("1-cpu"), Object: Pod}, Iteration: iteration}
+	}
+	expectedSteps := []*Step{
+		{Verb: Create, Create: &CreateStep{Count: 1, Class: Class("large"), Object: Node}},
+		{Verb: Assert, Assert: &AssertStep{Count: 1, Class: Class("large"), Object: Node}},
+		createPod("iteration 1/2"),
+		createPod("iteration 2/2"),
+		{Verb: Delete, Delete: &DeleteStep{Count: 1, Class: Class("large"), Object: Node}},
+	}
+
+	scenario, err := ScenarioFromFile(filepath.Join(dir, "scenario.yml"))
+	if err != nil {
+		t.Fatalf("expected err to be nil, but got: %s", err)
+	}
+	if !reflect.DeepEqual(expectedSteps, scenario.Steps) {
+		t.Fatalf("expected steps: %v, but got %v", expectedSteps, scenario.Steps)
+	}
+}
+
+func Test_ScenarioFromFile_invalidIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cycle.yml":          "steps:\n- include: common/a.yml\n",
+		"common/a.yml":       "steps:\n- include: b.yml\n",
+		"common/b.yml":       "steps:\n- include: ../cycle.yml\n",
+		"fragment.yml":       "steps:\n- include: common/c.yml#teardown\n",
+		"common/c.yml":       "fragments:\n  setup: [\"create 1 large node\"]\n",
+		"invalid.yml":        "steps:\n- \"create 1 large node\"\n- include: common/invalid.yml\n",
+		"common/invalid.yml": "steps:\n- \"create 1 large\"\n",
+		"missing.yml":        "steps:\n- include: common/missing.yml\n",
+		"self.yml":           "fragments:\n  loop: [{include: \"#loop\"}]\nsteps:\n- include: \"#loop\"\n",
+	})
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		desc string
+		file string
+		err  error
+	}{
+		{
+			desc: "cycle through files",
+			file: "cycle.yml",
+			err:  fmt.Errorf("unable to resolve includes: include cycle: %[1]s/cycle.yml -> %[1]s/common/a.yml -> %[1]s/common/b.yml -> %[1]s/cycle.yml", dir),
+		},
+		{
+			desc: "cycle through a fragment",
+			file: "self.yml",
+			err:  fmt.Errorf("unable to resolve includes: include cycle: %[1]s/self.yml -> %[1]s/self.yml#loop -> %[1]s/self.yml#loop", dir),
+		},
+		{
+			desc: "unknown fragment",
+			file: "fragment.yml",
+			err:  fmt.Errorf("unable to resolve includes: %[1]s/fragment.yml -> %[1]s/common/c.yml#teardown: no fragment `teardown` in %[1]s/common/c.yml", dir),
+		},
+		{
+			desc: "missing file",
+			file: "missing.yml",
+			err:  fmt.Errorf("unable to resolve includes: %[1]s/missing.yml -> %[1]s/common/missing.yml: open %[1]s/common/missing.yml: no such file or directory", dir),
+		},
+		{
+			desc: "invalid included step",
+			file: "invalid.yml",
+			err:  fmt.Errorf("unable to parse: step [1][0] in %[1]s/invalid.yml -> %[1]s/common/invalid.yml: column 15: expected <object> (\"pod[s]\" or \"node[s]\"), found end of step (input: `create 1 large`)", dir),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+
+		_, err := ScenarioFromFile(filepath.Join(dir, c.file))
+		if err == nil || err.Error() != c.err.Error() {
+			t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
@@ -37,6 +38,11 @@ func NodeConfigFromBytes(data []byte) (*NodeConfig, error) {
 			return nil, fmt.Errorf("node class name [%s] is not unique", name)
 		}
 		classNames[name] = true
+		if class.StartupLatency != "" {
+			if d, err := time.ParseDuration(class.StartupLatency); err != nil || d < 0 {
+				return nil, fmt.Errorf("node class [%s]: startupLatency [%s] is not a duration, e.g. 500ms", class.Name, class.StartupLatency)
+			}
+		}
 	}
 
 	return c, err
@@ -56,6 +62,19 @@ type NodeClass struct {
 	Count     uint
 	Labels    map[string]string
 	Resources NodeResources
+	// How long pods stay pending once they are bound to a node of the
+	// class, unless they set np.startupLatency, e.g. 500ms
+	StartupLatency string
+}
+
+// StartupLatencyOr returns the startup latency of the class, or def if the
+// class has none.
+func (c NodeClass) StartupLatencyOr(def time.Duration) time.Duration {
+	d, err := time.ParseDuration(c.StartupLatency)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
 }
 
 type NodeResources struct {
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_NodeClassStartupLatency(t *testing.T) {
+	cases := []struct {
+		desc     string
+		latency  string
+		expected time.Duration
+		err      error
+	}{
+		{desc: "unset", latency: "", expected: time.Second},
+		{desc: "set", latency: "500ms", expected: 500 * time.Millisecond},
+		{desc: "invalid", latency: "soon", err: fmt.Errorf("node class [large]: startupLatency [soon] is not a duration, e.g. 500ms")},
+		{desc: "negative", latency: "-1s", err: fmt.Errorf("node class [large]: startupLatency [-1s] is not a duration, e.g. 500ms")},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		nodeConfig, err := NodeConfigFromBytes([]byte(fmt.Sprintf("nodeClasses:\n- name: large\n  startupLatency: %q\n", c.latency)))
+		if c.err != nil {
+			if err == nil || err.Error() != c.err.Error() {
+				t.Fatalf("(case: %s) expected error: %s, but got %v", c.desc, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(case: %s) unexpected error: %s", c.desc, err.Error())
+		}
+		if actual := nodeConfig.NodeClasses[0].StartupLatencyOr(time.Second); actual != c.expected {
+			t.Fatalf("(case: %s) expected %s, got %s", c.desc, c.expected, actual)
+		}
+	}
+}
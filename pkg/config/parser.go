@@ -0,0 +1,441 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// StepGrammar is the grammar accepted by ParseStep. The copy in
+// doc/scenario.md must match it verbatim.
+//
+// Words are separated by whitespace. Keywords (the quoted literals) and
+// phases match case-insensitively; everything else keeps its case. Any word
+// may be double-quoted to include spaces or to stop it being read as a
+// keyword.
+const StepGrammar = `<step>        => <assertStep> | <createStep> | <changeStep> | <deleteStep>
+<assertStep>  => "assert" ( <count> [<class>] <object> [<is> ( <phase> | "unschedulable" ["with" "reason" <reason>] )] ["on" <class> <object>] | "api" <version> <kind> [<group>] ) [( "within" | <for> ) <duration>]
+<createStep>  => "create" <count> ( <class> <object> | "instance[s]" "of" <path/to/yaml/file> )
+<changeStep>  => "change" <count> <class> <object> "from" <phase> "to" <phase>
+<deleteStep>  => "delete" <count> ( <class> <object> | "instance[s]" "of" <path/to/yaml/file> )
+<is>          => "is" | "are"
+<for>         => "for" | "throughout"
+<count>       => [0-9]+
+<class>       => <word>
+<object>      => "pod[s]" | "node[s]"
+<phase>       => "Pending" | "Running" | "Succeeded" | "Failed" | "Unknown"
+<reason>      => <word> { <word> }
+<duration>    => time.Duration (e.g. 500ms, 5s, 1m)
+<word>        => non-whitespace characters | double-quoted string`
+
+// Every literal in StepGrammar, lowercased. The parser only matches these.
+var keywords = map[string]bool{
+	"assert": true, "create": true, "change": true, "delete": true,
+	"api": true, "unschedulable": true, "with": true, "reason": true, "on": true,
+	"within": true, "for": true, "throughout": true, "is": true, "are": true,
+	"instance": true, "instances": true, "of": true, "from": true, "to": true,
+	"pod": true, "pods": true, "node": true, "nodes": true,
+	"pending": true, "running": true, "succeeded": true, "failed": true, "unknown": true,
+}
+
+var phases = []v1.PodPhase{v1.PodPending, v1.PodRunning, v1.PodSucceeded, v1.PodFailed, v1.PodUnknown}
+
+var expectedPhase = fmt.Sprintf("<phase> (%s, %s, %s, %s or %s)", v1.PodPending, v1.PodRunning, v1.PodSucceeded, v1.PodFailed, v1.PodUnknown)
+
+// ParseStep parses a single scenario step according to StepGrammar.
+func ParseStep(raw string) (*Step, error) {
+	tokens, err := lex(raw)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, endColumn: len([]rune(raw)) + 1}
+	step, err := p.parseStep()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("end of step")
+	}
+	return step, nil
+}
+
+type token struct {
+	text   string
+	column int // 1-based, in runes
+	quoted bool
+}
+
+func (t token) String() string {
+	return strconv.Quote(t.text)
+}
+
+// Splits the input into whitespace separated words. A word starting with a
+// double quote extends to the matching closing quote and may contain
+// whitespace and the escapes \" and \\.
+func lex(input string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		start := i
+		if runes[i] == '"' {
+			var text strings.Builder
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+					text.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("column %d: unterminated quoted string", start+1)
+			}
+			tokens = append(tokens, token{text: text.String(), column: start + 1, quoted: true})
+			continue
+		}
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		tokens = append(tokens, token{text: string(runes[start:i]), column: start + 1})
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens    []token
+	pos       int
+	endColumn int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.done() {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+// Reports a syntax error at the current token.
+func (p *parser) errorf(expected string) error {
+	t, ok := p.peek()
+	if !ok {
+		return fmt.Errorf("column %d: expected %s, found end of step", p.endColumn, expected)
+	}
+	return fmt.Errorf("column %d: expected %s, found %s", t.column, expected, t)
+}
+
+// Reports whether the current token is one of the given keywords.
+func (p *parser) atKeyword(words ...string) bool {
+	t, ok := p.peek()
+	if !ok || t.quoted {
+		return false
+	}
+	for _, w := range words {
+		if !keywords[w] {
+			panic(fmt.Sprintf("%q is not a keyword of the step grammar", w))
+		}
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Consumes one of the given keywords, returning it in lower case.
+func (p *parser) keyword(words ...string) (string, error) {
+	if !p.atKeyword(words...) {
+		quoted := make([]string, len(words))
+		for i, w := range words {
+			quoted[i] = strconv.Quote(w)
+		}
+		return "", p.errorf(strings.Join(quoted, " or "))
+	}
+	return strings.ToLower(p.next().text), nil
+}
+
+// Consumes any word, keeping its case.
+func (p *parser) word(expected string) (string, error) {
+	if p.done() {
+		return "", p.errorf(expected)
+	}
+	return p.next().text, nil
+}
+
+func (p *parser) atDuration() bool {
+	return p.atKeyword("within", "for", "throughout")
+}
+
+func (p *parser) atObject() bool {
+	return p.atKeyword("pod", "pods", "node", "nodes")
+}
+
+// <step> => <assertStep> | <createStep> | <changeStep> | <deleteStep>
+func (p *parser) parseStep() (*Step, error) {
+	verb, err := p.keyword(string(Assert), string(Create), string(Change), string(Delete))
+	if err != nil {
+		return nil, err
+	}
+	step := &Step{Verb: Verb(verb)}
+	switch step.Verb {
+	case Assert:
+		step.Assert, err = p.parseAssertStep()
+	case Create:
+		step.Create, err = p.parseCreateStep()
+	case Change:
+		step.Change, err = p.parseChangeStep()
+	case Delete:
+		step.Delete, err = p.parseDeleteStep()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+// <assertStep> => "assert" ( <count> [<class>] <object> [<is> ( <phase> | "unschedulable" ["with" "reason" <reason>] )] ["on" <class> <object>] | "api" <version> <kind> [<group>] ) [( "within" | <for> ) <duration>]
+func (p *parser) parseAssertStep() (*AssertStep, error) {
+	result := &AssertStep{}
+	var err error
+
+	if p.atKeyword("api") {
+		p.next()
+		result.GVK = &schema.GroupVersionKind{}
+		if result.GVK.Version, err = p.word("<version>"); err != nil {
+			return nil, err
+		}
+		if p.atDuration() {
+			return nil, p.errorf("<kind>")
+		}
+		if result.GVK.Kind, err = p.word("<kind>"); err != nil {
+			return nil, err
+		}
+		if !p.done() && !p.atDuration() {
+			result.GVK.Group = p.next().text
+		}
+	} else {
+		if result.Count, err = p.parseCount(); err != nil {
+			return nil, err
+		}
+		if !p.atObject() {
+			class, err := p.word("<class> or <object>")
+			if err != nil {
+				return nil, err
+			}
+			result.Class = Class(class)
+		}
+		if result.Object, err = p.parseObject(); err != nil {
+			return nil, err
+		}
+
+		if p.atKeyword("is", "are") {
+			p.next()
+			if p.atKeyword("unschedulable") {
+				if result.Object != Pod {
+					return nil, p.errorf(expectedPhase)
+				}
+				p.next()
+				result.Unschedulable = true
+				if p.atKeyword("with") {
+					p.next()
+					if _, err := p.keyword("reason"); err != nil {
+						return nil, err
+					}
+					if result.Reason, err = p.parseReason(); err != nil {
+						return nil, err
+					}
+				}
+			} else if result.PodPhase, err = p.parsePhase(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.atKeyword("on") {
+			if result.Object != Pod || result.Unschedulable {
+				return nil, p.errorf("end of step or <duration>")
+			}
+			p.next()
+			class, err := p.word("<class>")
+			if err != nil {
+				return nil, err
+			}
+			result.NodeClass = Class(class)
+			if !p.atKeyword("node", "nodes") {
+				return nil, p.errorf(`"node[s]"`)
+			}
+			p.next()
+		}
+	}
+
+	if p.atDuration() {
+		keyword := strings.ToLower(p.next().text)
+		duration, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		if keyword == "within" {
+			result.Delay = duration
+		} else {
+			result.Duration = duration
+		}
+	}
+	return result, nil
+}
+
+// <createStep> => "create" <count> ( <class> <object> | "instance[s]" "of" <path/to/yaml/file> )
+func (p *parser) parseCreateStep() (*CreateStep, error) {
+	count, class, object, path, err := p.parseTarget()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateStep{Count: count, Class: class, Object: object, YamlPath: path}, nil
+}
+
+// <changeStep> => "change" <count> <class> <object> "from" <phase> "to" <phase>
+func (p *parser) parseChangeStep() (*ChangeStep, error) {
+	result := &ChangeStep{}
+	var err error
+	if result.Count, err = p.parseCount(); err != nil {
+		return nil, err
+	}
+	class, err := p.word("<class>")
+	if err != nil {
+		return nil, err
+	}
+	result.Class = Class(class)
+	if result.Object, err = p.parseObject(); err != nil {
+		return nil, err
+	}
+	if _, err = p.keyword("from"); err != nil {
+		return nil, err
+	}
+	if result.FromPodPhase, err = p.parsePhase(); err != nil {
+		return nil, err
+	}
+	if _, err = p.keyword("to"); err != nil {
+		return nil, err
+	}
+	if result.ToPodPhase, err = p.parsePhase(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// <deleteStep> => "delete" <count> ( <class> <object> | "instance[s]" "of" <path/to/yaml/file> )
+func (p *parser) parseDeleteStep() (*DeleteStep, error) {
+	count, class, object, path, err := p.parseTarget()
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteStep{Count: count, Class: class, Object: object, YamlPath: path}, nil
+}
+
+// <count> ( <class> <object> | "instance[s]" "of" <path/to/yaml/file> )
+func (p *parser) parseTarget() (count uint64, class Class, object Object, path string, err error) {
+	if count, err = p.parseCount(); err != nil {
+		return
+	}
+	if p.atKeyword("instance", "instances") {
+		p.next()
+		if _, err = p.keyword("of"); err != nil {
+			return
+		}
+		path, err = p.word("<path/to/yaml/file>")
+		return
+	}
+	var c string
+	if c, err = p.word(`<class> or "instance[s]"`); err != nil {
+		return
+	}
+	class = Class(c)
+	object, err = p.parseObject()
+	return
+}
+
+// <count> => [0-9]+
+func (p *parser) parseCount() (uint64, error) {
+	t, ok := p.peek()
+	if !ok {
+		return 0, p.errorf("<count>")
+	}
+	count, err := strconv.ParseUint(t.text, 10, 64)
+	if err != nil {
+		return 0, p.errorf("<count>")
+	}
+	p.next()
+	return count, nil
+}
+
+// <object> => "pod[s]" | "node[s]"
+func (p *parser) parseObject() (Object, error) {
+	if !p.atObject() {
+		return "", p.errorf(`<object> ("pod[s]" or "node[s]")`)
+	}
+	o := strings.ToLower(p.next().text)
+	return Object(strings.TrimSuffix(o, "s")), nil
+}
+
+// <phase> => "Pending" | "Running" | "Succeeded" | "Failed" | "Unknown"
+func (p *parser) parsePhase() (v1.PodPhase, error) {
+	t, ok := p.peek()
+	if ok && !t.quoted {
+		for _, phase := range phases {
+			if strings.EqualFold(t.text, string(phase)) {
+				p.next()
+				return phase, nil
+			}
+		}
+	}
+	return "", p.errorf(expectedPhase)
+}
+
+// <reason> => <word> { <word> }
+//
+// Unquoted reasons extend up to the optional duration.
+func (p *parser) parseReason() (string, error) {
+	words := []string{}
+	for !p.done() && !p.atDuration() {
+		words = append(words, p.next().text)
+	}
+	if len(words) == 0 {
+		return "", p.errorf("<reason>")
+	}
+	return strings.Join(words, " "), nil
+}
+
+// <duration> => time.Duration
+func (p *parser) parseDuration() (time.Duration, error) {
+	t, ok := p.peek()
+	if !ok {
+		return 0, p.errorf(`<duration> (e.g. "500ms" or "5s")`)
+	}
+	d, err := time.ParseDuration(t.text)
+	if err != nil {
+		return 0, p.errorf(`<duration> (e.g. "500ms" or "5s")`)
+	}
+	p.next()
+	return d, nil
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+func Test_ParseStep(t *testing.T) {
+
+	cases := []struct {
+		desc         string
+		raw          string
+		expectedStep *Step
+		err          error
+	}{
+		{
+			desc: "create <count> <class> <object>, class keeps its case",
+			raw:  "create 2 Large-GPU nodes",
+			expectedStep: &Step{
+				Verb:   Create,
+				Create: &CreateStep{Count: 2, Class: Class("Large-GPU"), Object: Node},
+			},
+			err: nil,
+		},
+		{
+			desc: "create <count> instance of <path>, path keeps its case",
+			raw:  "create 1 instance of Job.yml",
+			expectedStep: &Step{
+				Verb:   Create,
+				Create: &CreateStep{Count: 1, YamlPath: "Job.yml"},
+			},
+			err: nil,
+		},
+		{
+			desc: "create <count> instances of <quoted path>",
+			raw:  `create 1 instances of "my jobs/Job 1.yml"`,
+			expectedStep: &Step{
+				Verb:   Create,
+				Create: &CreateStep{Count: 1, YamlPath: "my jobs/Job 1.yml"},
+			},
+			err: nil,
+		},
+		{
+			desc: "keywords are case-insensitive and whitespace is collapsed",
+			raw:  "  Assert  3   1-cpu PODS are running\twithin 500ms ",
+			expectedStep: &Step{
+				Verb: Assert,
+				Assert: &AssertStep{
+					Count:    3,
+					Class:    Class("1-cpu"),
+					Object:   Pod,
+					PodPhase: v1.PodRunning,
+					Delay:    500 * time.Millisecond,
+				},
+			},
+			err: nil,
+		},
+		{
+			desc: "quoted class is never a keyword",
+			raw:  `assert 1 "pods" node`,
+			expectedStep: &Step{
+				Verb:   Assert,
+				Assert: &AssertStep{Count: 1, Class: Class("pods"), Object: Node},
+			},
+			err: nil,
+		},
+		{
+			desc: "quoted string with escapes",
+			raw:  `assert 1 pod is unschedulable with reason "say \"no\" \\ twice"`,
+			expectedStep: &Step{
+				Verb: Assert,
+				Assert: &AssertStep{
+					Count:         1,
+					Object:        Pod,
+					Unschedulable: true,
+					Reason:        `say "no" \ twice`,
+				},
+			},
+			err: nil,
+		},
+		{
+			desc: "change <count> <class> <object> from <phase> to <phase>",
+			raw:  "change 1 1-cpu pod from Running to Failed",
+			expectedStep: &Step{
+				Verb: Change,
+				Change: &ChangeStep{
+					Count:        1,
+					Class:        Class("1-cpu"),
+					Object:       Pod,
+					FromPodPhase: v1.PodRunning,
+					ToPodPhase:   v1.PodFailed,
+				},
+			},
+			err: nil,
+		},
+		{
+			desc: "delete <count> <class> <object>",
+			raw:  "delete 2 small nodes",
+			expectedStep: &Step{
+				Verb:   Delete,
+				Delete: &DeleteStep{Count: 2, Class: Class("small"), Object: Node},
+			},
+			err: nil,
+		},
+		{
+			desc: "delete <count> instance of <path>",
+			raw:  "delete 1 instance of cr.yml",
+			expectedStep: &Step{
+				Verb:   Delete,
+				Delete: &DeleteStep{Count: 1, YamlPath: "cr.yml"},
+			},
+			err: nil,
+		},
+
+		// Negative tests
+		{
+			desc:         "unknown verb",
+			raw:          "destroy 1 pod",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 1: expected "assert" or "create" or "change" or "delete", found "destroy"`),
+		},
+		{
+			desc:         "empty step",
+			raw:          "",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 1: expected "assert" or "create" or "change" or "delete", found end of step`),
+		},
+		{
+			desc:         "missing count",
+			raw:          "create large node",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 8: expected <count>, found "large"`),
+		},
+		{
+			desc:         "unterminated quote",
+			raw:          `create 1 instance of "job.yml`,
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 22: unterminated quoted string`),
+		},
+		{
+			desc:         "instance without of",
+			raw:          "create 1 instance job.yml",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 19: expected "of", found "job.yml"`),
+		},
+		{
+			desc:         "trailing words",
+			raw:          "create 1 large node now",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 21: expected end of step, found "now"`),
+		},
+		{
+			desc:         "<is> without a phase",
+			raw:          "assert 3 x pods are on y nodes",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 21: expected <phase> (Pending, Running, Succeeded, Failed or Unknown), found "on"`),
+		},
+		{
+			desc:         "<is> followed by a duration",
+			raw:          "assert 1 large node is within 5s",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 24: expected <phase> (Pending, Running, Succeeded, Failed or Unknown), found "within"`),
+		},
+		{
+			desc:         "change without to",
+			raw:          "change 1 1-cpu pod from Running",
+			expectedStep: nil,
+			err:          fmt.Errorf(`column 32: expected "to", found end of step`),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+
+		actualStep, err := ParseStep(c.raw)
+		if c.err != nil {
+			if err == nil || err.Error() != c.err.Error() {
+				t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
+			}
+		} else if err != c.err {
+			t.Fatalf("(case: %s) expected err to be nil, but got: %s", c.desc, err)
+		}
+		if !reflect.DeepEqual(c.expectedStep, actualStep) {
+			t.Fatalf("(case: %s) expected step: %v, but got %v", c.desc, c.expectedStep, actualStep)
+		}
+	}
+}
+
+func Test_StepGrammarIsDocumented(t *testing.T) {
+	data, err := ioutil.ReadFile("../../doc/scenario.md")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(data), "```\n"+StepGrammar+"\n```") {
+		t.Fatalf("the grammar in doc/scenario.md does not match config.StepGrammar:\n%s", StepGrammar)
+	}
+}
+
+func Test_StepGrammarKeywords(t *testing.T) {
+	literals := map[string]bool{}
+	for _, m := range regexp.MustCompile(`"([^"]+)"`).FindAllStringSubmatch(StepGrammar, -1) {
+		literal := strings.ToLower(m[1])
+		if strings.HasSuffix(literal, "[s]") {
+			literal = strings.TrimSuffix(literal, "[s]")
+			literals[literal+"s"] = true
+		}
+		literals[literal] = true
+	}
+	if !reflect.DeepEqual(literals, keywords) {
+		t.Fatalf("keywords do not match the literals of config.StepGrammar\nexpected: %v\nbut got:  %v", literals, keywords)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,11 +15,17 @@ import (
 )
 
 func ScenarioFromFile(path string) (*Scenario, error) {
+	return ScenarioFromFileWithParams(path, nil)
+}
+
+// ScenarioFromFileWithParams loads a scenario, overriding the defaults of its
+// params section, e.g. with {"nodes": "1000"}.
+func ScenarioFromFileWithParams(path string, overrides map[string]string) (*Scenario, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	scenario, err := ScenarioFromBytes(data)
+	scenario, err := scenarioFromBytes(data, path, overrides)
 	if err != nil {
 		return nil, err
 	}
@@ -27,354 +34,456 @@ func ScenarioFromFile(path string) (*Scenario, error) {
 }
 
 func ScenarioFromBytes(data []byte) (*Scenario, error) {
+	return ScenarioFromBytesWithParams(data, nil)
+}
+
+// Includes in scenarios loaded from bytes are resolved relative to the
+// current directory.
+func ScenarioFromBytesWithParams(data []byte, overrides map[string]string) (*Scenario, error) {
+	return scenarioFromBytes(data, "", overrides)
+}
+
+func scenarioFromBytes(data []byte, path string, overrides map[string]string) (*Scenario, error) {
 	sy := ScenarioYaml{}
 	err := yaml.Unmarshal(data, &sy)
 	if err != nil {
 		return nil, err
 	}
 
-	steps, err := ParseSteps(sy.RawSteps)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse: %s", err.Error())
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	resolver := newIncludeResolver(path, &sy)
+	for _, section := range sy.sections() {
+		if *section.raw, err = resolver.resolve(*section.raw, path, []string{resolver.label(path, "")}); err != nil {
+			return nil, fmt.Errorf("unable to resolve includes: %s", err.Error())
+		}
+	}
+
+	if sy.Params == nil {
+		sy.Params = map[string]string{}
+	}
+	for name := range sy.Params {
+		if !variableName.MatchString(name) {
+			return nil, fmt.Errorf("invalid parameter name `%s`", name)
+		}
+	}
+	for name, value := range overrides {
+		if _, ok := sy.Params[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter `%s`, the scenario declares: %s", name, strings.Join(sy.ParamNames(), ", "))
+		}
+		sy.Params[name] = value
+	}
+
+	scenario := &Scenario{ScenarioYaml: sy}
+	parsed := map[string]*[]*Step{
+		"setup":     &scenario.Setup,
+		"":          &scenario.Steps,
+		"teardown":  &scenario.Teardown,
+		"onFailure": &scenario.OnFailure,
 	}
-	if len(steps) != len(sy.RawSteps) {
-		return nil, fmt.Errorf("number of parsed steps does not equal raw input steps")
+	for _, section := range sy.sections() {
+		steps, err := parseSteps(*section.raw, "", stepScope{section: section.name, vars: sy.Params})
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse: %s", err.Error())
+		}
+		*parsed[section.name] = steps
 	}
 
-	return &Scenario{ScenarioYaml: sy, Steps: steps}, nil
+	return scenario, nil
 }
 
 type Scenario struct {
 	ScenarioYaml
-	Steps []*Step
+	// Run in order: setup, then steps, then onFailure if setup or steps
+	// failed. Teardown always runs last.
+	Setup     []*Step
+	Steps     []*Step
+	Teardown  []*Step
+	OnFailure []*Step
 }
 
 type ScenarioYaml struct {
-	Name       string
-	Version    uint64
-	RawSteps   []string `yaml:"steps"`
+	Name    string
+	Version uint64
+	// Defaults of the variables steps can reference as ${name}, after
+	// overrides are applied
+	Params map[string]string `yaml:"params,omitempty"`
+	// Record failed asserts and run the remaining steps instead of stopping
+	ContinueOnFailure bool      `yaml:"continueOnFailure,omitempty"`
+	RawSetup          []RawStep `yaml:"setup,omitempty"`
+	RawSteps          []RawStep `yaml:"steps"`
+	// Always run after setup and steps, whether or not they succeeded
+	RawTeardown []RawStep `yaml:"teardown,omitempty"`
+	// Run when setup or steps fail, e.g. to collect diagnostics
+	RawOnFailure []RawStep `yaml:"onFailure,omitempty"`
+	// Named step lists that steps can include as #name, or other files as
+	// path#name
+	Fragments  map[string][]RawStep `yaml:"fragments,omitempty"`
 	WorkingDir string
 }
 
-func ParseSteps(rawSteps []string) ([]*Step, error) {
-	steps := []*Step{}
-	for i, raw := range rawSteps {
-		step, err := ParseStep(raw)
-		if err != nil {
-			return nil, fmt.Errorf("step [%d]: %s (input: `%s`", i, err.Error(), raw)
-		}
-		steps = append(steps, step)
+type stepSection struct {
+	// Empty for the main steps
+	name string
+	raw  *[]RawStep
+}
+
+func (sy *ScenarioYaml) sections() []stepSection {
+	return []stepSection{
+		{name: "setup", raw: &sy.RawSetup},
+		{name: "", raw: &sy.RawSteps},
+		{name: "teardown", raw: &sy.RawTeardown},
+		{name: "onFailure", raw: &sy.RawOnFailure},
 	}
-	return steps, nil
 }
 
-// Step grammar:
+// ParamNames returns the names of the scenario's params in sorted order.
+func (sy *ScenarioYaml) ParamNames() []string {
+	names := []string{}
+	for name := range sy.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RawStep is one entry of a scenario's steps: either a string in the step
+// grammar, a structured step or a block of steps, e.g.
 //
-// <step>        => <assertStep> | <createStep> | <changeStep> | <deleteStep>
-// <assertStep>  => "assert" ( <count> [<class>] <object> [<is> <phase>] | api  <version> <kind> [<group>] ) [<within> <duration>]
-// <createStep>  => "create" <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )
-// <changeStep>  => "change" <count> <class> <object> "from" <phase> "to" <phase>
-// <deleteStep>  => "delete" <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )
-// <is>         => "is" | "are"
-// <count>      => [1-9][0-9]*
-// <class>      => [A-Za-z0-9\-]+
-// <object>     => "pod[s]" | "node[s]"
-// <phase>      => "Pending" | "Running" | "Succeeded" | "Failed" | "Unknown"
-// <duration>   => time.Duration
-
-func ParseStep(raw string) (*Step, error) {
-	raw = strings.ToLower(raw)
-	parts := strings.Split(raw, " ")
-	if len(parts) < 3 {
-		return nil, fmt.Errorf(`not enough words (need at least: "verb count object"), but given: %s`, raw)
-	}
-
-	step := &Step{
-		Verb: Verb(strings.TrimSpace(parts[0])),
-	}
-
-	var count uint64
-	apiAssert := false
-	count, err := parseCount(parts[1])
-	if err != nil {
-		if parts[1] == "api" {
-			count = 0
-			apiAssert = true
-		} else {
-			return nil, err
-		}
+//   - "assert 3 1-cpu pods are Running within 5s"
+//   - assert: {count: 3, class: 1-cpu, object: pod, phase: Running, within: 5s}
+//   - repeat: 50
+//     steps: ["create 10 1-cpu pods", "delete 10 1-cpu pods"]
+type RawStep struct {
+	Text       string
+	Structured *Step
+	Block      *Block
+	// A file, a fragment of a file (path#name) or a fragment of the
+	// including file (#name), resolved by ScenarioFromFile
+	Include string
+	// Set when the include is resolved
+	included []RawStep
+	source   string
+	// The undecoded structured step, kept when it references variables
+	// where the step expects a number, e.g. count: ${nodes}
+	node interface{}
+}
+
+// Block runs its steps a number of times (repeat), or once for each value
+// of a variable (forEach ... in) that the steps reference as ${name}.
+type Block struct {
+	Repeat  string    `yaml:"repeat,omitempty"` // a count, or a ${expr} evaluating to one
+	ForEach string    `yaml:"forEach,omitempty"`
+	In      []string  `yaml:"in,omitempty"`
+	Steps   []RawStep `yaml:"steps"`
+}
+
+func (r *RawStep) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&r.Text); err == nil {
+		return nil
 	}
-	predicate := parts[2:]
-	switch step.Verb {
-	case Assert:
-		a, err := parseAssertStep(count, predicate, apiAssert)
-		if err != nil {
-			return nil, err
+	keys := map[string]interface{}{}
+	if err := unmarshal(&keys); err != nil {
+		return err
+	}
+	if _, ok := keys["include"]; ok {
+		include := struct {
+			Include string `yaml:"include"`
+		}{}
+		if err := unmarshal(&include); err != nil {
+			return err
 		}
-		step.Assert = a
-		return step, nil
-	case Create:
-		c, err := parseCreateStep(count, predicate)
-		if err != nil {
-			return nil, err
+		if len(keys) != 1 || include.Include == "" {
+			return fmt.Errorf("an include step needs a path and nothing else")
 		}
-		step.Create = c
-		return step, nil
-	case Change:
-		c, err := parseChangeStep(count, predicate)
-		if err != nil {
-			return nil, err
+		r.Include = include.Include
+		return nil
+	}
+	if _, ok := keys["steps"]; ok {
+		block := &Block{}
+		if err := unmarshal(block); err != nil {
+			return err
 		}
-		step.Change = c
-		return step, nil
-	case Delete:
-		d, err := parseDeleteStep(count, predicate)
-		if err != nil {
-			return nil, err
+		r.Block = block
+		return nil
+	}
+	step := &Step{}
+	if err := unmarshal(step); err != nil {
+		if !variableReference.MatchString(fmt.Sprintf("%v", keys)) {
+			return err
 		}
-		step.Delete = d
-		return step, nil
+		r.node = keys
+		return nil
 	}
-	return nil, fmt.Errorf(`unknown verb: "%s"`, step.Verb)
+	r.Structured = step
+	return nil
 }
 
-func parseCount(raw string) (uint64, error) {
-	return strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+func (r RawStep) MarshalYAML() (interface{}, error) {
+	if r.Include != "" {
+		return map[string]string{"include": r.Include}, nil
+	}
+	if r.Block != nil {
+		return r.Block, nil
+	}
+	if r.Structured != nil {
+		return r.Structured, nil
+	}
+	if r.node != nil {
+		return r.node, nil
+	}
+	return r.Text, nil
 }
 
-func getNext(array []string) (string, []string, error) {
-	if len(array) == 0 {
-		return "", []string{}, fmt.Errorf("Insufficient elements in array")
+// Returns the step as written, or in the step grammar if it is structured.
+func (r RawStep) String() string {
+	if r.Include != "" {
+		return "include " + r.Include
+	}
+	if r.Block != nil {
+		return r.Block.String()
+	}
+	if r.Structured != nil {
+		return r.Structured.String()
+	}
+	if r.node != nil {
+		return fmt.Sprintf("%v", r.node)
 	}
-	next, rem := array[0], array[1:]
-	return next, rem, nil
+	return r.Text
 }
 
-// <assertStep> => "assert" ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]
-func parseAssertStep(count uint64, predicate []string, apiAssert bool) (*AssertStep, error) {
-	result := &AssertStep{Count: count}
-
-	// Check for the first 2 predicates.
-	// Check if first predicate is object
-	next, rem, err := getNext(predicate)
-	if err != nil {
-		return nil, fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]")
+func (b *Block) String() string {
+	if b.ForEach != "" {
+		return fmt.Sprintf("for each %s in [%s]", b.ForEach, strings.Join(b.In, ", "))
 	}
+	return fmt.Sprintf("repeat %s times", b.Repeat)
+}
 
-	if apiAssert {
-		result.GVK = &schema.GroupVersionKind{
-			Version: next,
-		}
-		next, rem, err = getNext(rem)
-		if err != nil || next == "within" {
-			return nil, fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]")
+func (b *Block) validate() error {
+	if (b.Repeat == "") == (b.ForEach == "") {
+		return fmt.Errorf("a block needs exactly one of repeat or forEach")
+	}
+	if b.ForEach != "" {
+		if !variableName.MatchString(b.ForEach) {
+			return fmt.Errorf("invalid variable name `%s`", b.ForEach)
 		}
-		result.GVK.Kind = next
-
-		next, rem, err = getNext(rem)
-		if err != nil {
-			return result, nil
+		if len(b.In) == 0 {
+			return fmt.Errorf("forEach needs at least one value in `in`")
 		}
-		if next != "within" {
-			result.GVK.Group = next
+	}
+	if len(b.Steps) == 0 {
+		return fmt.Errorf("a block needs at least one step")
+	}
+	return nil
+}
 
-			next, rem, err = getNext(rem)
+// Returns the variables and iteration label of each run of the block.
+func (b *Block) iterations(vars map[string]string) ([]map[string]string, []string, error) {
+	scopes := []map[string]string{}
+	labels := []string{}
+	if b.ForEach != "" {
+		for _, in := range b.In {
+			value, err := substitute(in, vars)
 			if err != nil {
-				return result, nil
+				return nil, nil, err
 			}
+			scope := map[string]string{}
+			for k, v := range vars {
+				scope[k] = v
+			}
+			scope[b.ForEach] = value
+			scopes = append(scopes, scope)
+			labels = append(labels, fmt.Sprintf("%s=%s", b.ForEach, value))
 		}
-	} else {
+		return scopes, labels, nil
+	}
+	repeat, err := substitute(b.Repeat, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := strconv.ParseUint(repeat, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("repeat must be a count: (found `%s`)", repeat)
+	}
+	for i := uint64(1); i <= n; i++ {
+		scopes = append(scopes, vars)
+		labels = append(labels, fmt.Sprintf("iteration %d/%d", i, n))
+	}
+	return scopes, labels, nil
+}
 
-		obj, err := parseObject(next)
-		if err != nil {
-			// Check if count is provided and check if second predicate is object
-			var e error
-			next, rem, e = getNext(rem)
-			if e != nil {
-				return nil, err
-			}
+// ParseSteps parses the raw steps, substituting the params they reference,
+// and expands repeat and forEach blocks into the steps they run, in order.
+func ParseSteps(rawSteps []RawStep, params map[string]string) ([]*Step, error) {
+	if params == nil {
+		params = map[string]string{}
+	}
+	return parseSteps(rawSteps, "", stepScope{vars: params})
+}
+
+// Where a step is expanded: the variables in scope, the iteration of the
+// enclosing blocks and the chain of includes that led to it.
+type stepScope struct {
+	section   string
+	vars      map[string]string
+	iteration string
+	source    string
+}
+
+func (sc stepScope) describe(index string) string {
+	where := "step " + index
+	if sc.section != "" {
+		where = sc.section + " " + where
+	}
+	if sc.iteration != "" {
+		where += fmt.Sprintf(" (%s)", sc.iteration)
+	}
+	if sc.source != "" {
+		where += " in " + sc.source
+	}
+	return where
+}
 
-			obj, err = parseObject(next)
+func parseSteps(rawSteps []RawStep, prefix string, sc stepScope) ([]*Step, error) {
+	steps := []*Step{}
+	for i, raw := range rawSteps {
+		index := fmt.Sprintf("%s[%d]", prefix, i)
+		if raw.Include != "" {
+			if raw.included == nil {
+				return nil, fmt.Errorf("%s: include `%s` was not resolved", sc.describe(index), raw.Include)
+			}
+			included := sc
+			included.source = raw.source
+			includedSteps, err := parseSteps(raw.included, index, included)
 			if err != nil {
 				return nil, err
 			}
-
-			// This means first is class
-			result.Class = Class(predicate[0])
-			result.Object = obj
-		} else {
-			// No count is provided, use object instead.
-			result.Object = obj
+			steps = append(steps, includedSteps...)
+			continue
 		}
-
-		// Now check if either phase is provided or delay is provided.
-		next, rem, err = getNext(rem)
-		if err != nil {
-			return result, nil
-		}
-		// Check if there is a "is" or "are"
-		if next == "is" || next == "are" {
-			next, rem, err = getNext(rem)
+		if raw.Block != nil {
+			err := raw.Block.validate()
+			var scopes []map[string]string
+			var labels []string
+			if err == nil {
+				scopes, labels, err = raw.Block.iterations(sc.vars)
+			}
 			if err != nil {
-				return nil, fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]")
+				return nil, fmt.Errorf("%s: %s (input: `%s`)", sc.describe(index), err.Error(), raw)
 			}
-			ph, err := parsePhase(next)
-
-			if err == nil {
-
-				result.PodPhase = ph
-				next, rem, err = getNext(rem)
+			for j, vars := range scopes {
+				inner := sc
+				inner.vars = vars
+				inner.iteration = labels[j]
+				if sc.iteration != "" {
+					inner.iteration = sc.iteration + ", " + labels[j]
+				}
+				blockSteps, err := parseSteps(raw.Block.Steps, index, inner)
 				if err != nil {
-					return result, nil
+					return nil, err
 				}
-			} else if next != "within" {
-				return nil, err
+				steps = append(steps, blockSteps...)
 			}
+			continue
 		}
-	}
-	// Check if there is within
-	if next == "within" {
-		next, rem, err = getNext(rem)
-		if err != nil {
-			return nil, fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]")
-		}
-		duration, err := time.ParseDuration(next)
+
+		step, err := parseStep(raw, sc.vars)
 		if err != nil {
-			return nil, fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]")
+			return nil, fmt.Errorf("%s: %s (input: `%s`)", sc.describe(index), err.Error(), raw)
 		}
-		result.Delay = duration
-	} else if next != "" {
-		return nil, fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]")
+		step.Iteration = sc.iteration
+		steps = append(steps, step)
 	}
-
-	return result, nil
+	return steps, nil
 }
 
-// <createStep> => "create" <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )
-func parseCreateStep(count uint64, predicate []string) (*CreateStep, error) {
-	if len(predicate) != 2 && len(predicate) != 3 {
-		return nil, fmt.Errorf("syntax: create <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )")
-	}
-
-	var result *CreateStep
-	if len(predicate) == 3 {
-		instanceString := strings.TrimRight(strings.TrimSpace(predicate[0]), "s")
-		if instanceString != "instance" || predicate[1] != "of" {
-			return nil, fmt.Errorf("syntax: create <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )")
-		}
-
-		result = &CreateStep{
-			Count:    count,
-			YamlPath: predicate[2],
-		}
-	} else {
-		obj, err := parseObject(predicate[1])
+// Parses a single step after substituting the variables in scope. Structured
+// steps are decoded afresh, so that steps repeated by a block do not share
+// state.
+func parseStep(raw RawStep, vars map[string]string) (*Step, error) {
+	if raw.Structured == nil && raw.node == nil {
+		text, err := substitute(raw.Text, vars)
 		if err != nil {
 			return nil, err
 		}
-		result = &CreateStep{
-			Count:  count,
-			Class:  Class(predicate[0]),
-			Object: obj,
+		step, err := ParseStep(text)
+		if err != nil && text != raw.Text {
+			return nil, fmt.Errorf("%s in `%s`", err.Error(), text)
 		}
+		return step, err
 	}
-	return result, nil
-}
 
-// <changeStep> => "change" <count> <class> <object> "from" <phase> "to" <phase>
-func parseChangeStep(count uint64, predicate []string) (*ChangeStep, error) {
-	if len(predicate) != 6 {
-		return nil, fmt.Errorf("syntax: change <count> <class> <object> from <phase> to <phase>")
+	var node interface{}
+	source := raw.node
+	if source == nil {
+		source = raw.Structured
 	}
-	class := Class(predicate[0])
-	obj, err := parseObject(predicate[1])
+	data, err := yaml.Marshal(source)
 	if err != nil {
 		return nil, err
 	}
-	fromPhase, err := parsePhase(predicate[3])
-	if err != nil {
+	if err := yaml.Unmarshal(data, &node); err != nil {
 		return nil, err
 	}
-	toPhase, err := parsePhase(predicate[5])
-	if err != nil {
+	if node, err = substituteNode(node, vars); err != nil {
 		return nil, err
 	}
-	result := &ChangeStep{
-		Count:        count,
-		Class:        class,
-		Object:       obj,
-		FromPodPhase: fromPhase,
-		ToPodPhase:   toPhase,
+	if data, err = yaml.Marshal(node); err != nil {
+		return nil, err
+	}
+	step := &Step{}
+	if err := yaml.Unmarshal(data, step); err != nil {
+		return nil, err
 	}
-	return result, nil
+	if err := step.Validate(); err != nil {
+		return nil, err
+	}
+	return step, nil
 }
 
-// <deleteStep> => "delete" <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )
-func parseDeleteStep(count uint64, predicate []string) (*DeleteStep, error) {
-	if len(predicate) > 2 && len(predicate) == 0 {
-		return nil, fmt.Errorf("syntax: delete <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )")
-	}
-	var result *DeleteStep
-	if len(predicate) == 3 {
-		instanceString := strings.TrimRight(strings.TrimSpace(predicate[0]), "s")
-		if instanceString != "instance" || predicate[1] != "of" {
-			return nil, fmt.Errorf("syntax: delete <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )")
+// Substitutes variables in all strings of a decoded yaml document. Strings
+// that become integers are replaced by numbers, so that they decode into
+// counts.
+func substituteNode(node interface{}, vars map[string]string) (interface{}, error) {
+	switch n := node.(type) {
+	case string:
+		value, err := substitute(n, vars)
+		if err != nil || value == n {
+			return value, err
 		}
-
-		result = &DeleteStep{
-			Count:    count,
-			YamlPath: predicate[2],
+		if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return number, nil
 		}
-	} else {
-		obj, err := parseObject(predicate[1])
-		if err != nil {
-			return nil, err
+		return value, nil
+	case map[interface{}]interface{}:
+		for k, v := range n {
+			value, err := substituteNode(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			n[k] = value
 		}
-		result = &DeleteStep{
-			Count:  count,
-			Class:  Class(predicate[0]),
-			Object: obj,
+	case []interface{}:
+		for i, v := range n {
+			value, err := substituteNode(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = value
 		}
 	}
-	return result, nil
-}
-
-func parseObject(o string) (Object, error) {
-	canonical := strings.TrimRight(strings.TrimSpace(o), "s")
-	obj := Object(canonical)
-	switch obj {
-	case Pod:
-		return Pod, nil
-	case Node:
-		return Node, nil
-	}
-	return obj, fmt.Errorf("object must be either `node` or `pod`: (found `%s`)", obj)
-}
-
-func parsePhase(p string) (v1.PodPhase, error) {
-	ph := v1.PodPhase(strings.Title(strings.TrimSpace(p)))
-	switch ph {
-	case v1.PodPending:
-		return v1.PodPending, nil
-	case v1.PodRunning:
-		return v1.PodRunning, nil
-	case v1.PodSucceeded:
-		return v1.PodSucceeded, nil
-	case v1.PodFailed:
-		return v1.PodFailed, nil
-	case v1.PodUnknown:
-		return v1.PodUnknown, nil
-	}
-	return ph, fmt.Errorf("phase must be one of %s, %s, %s, %s or %s: (found `%s`)",
-		v1.PodPending, v1.PodRunning, v1.PodSucceeded, v1.PodFailed, v1.PodUnknown, ph)
+	return node, nil
 }
 
 type Step struct {
-	Verb   Verb
-	Assert *AssertStep
-	Create *CreateStep
-	Change *ChangeStep
-	Delete *DeleteStep
+	Verb   Verb        `yaml:"verb,omitempty"`
+	Assert *AssertStep `yaml:"assert,omitempty"`
+	Create *CreateStep `yaml:"create,omitempty"`
+	Change *ChangeStep `yaml:"change,omitempty"`
+	Delete *DeleteStep `yaml:"delete,omitempty"`
+	// Set for steps expanded from a block, e.g. "iteration 2/50"
+	Iteration string `yaml:"-"`
 }
 
 func (s *Step) AsYaml() string {
@@ -383,34 +492,40 @@ func (s *Step) AsYaml() string {
 }
 
 type AssertStep struct {
-	Count    uint64
-	Class    Class // optional
-	Object   Object
-	PodPhase v1.PodPhase // optional
-	Delay    time.Duration
-	GVK      *schema.GroupVersionKind
+	Count     uint64      `yaml:"count"`
+	Class     Class       `yaml:"class,omitempty"` // optional
+	Object    Object      `yaml:"object,omitempty"`
+	PodPhase  v1.PodPhase `yaml:"phase,omitempty"`     // optional
+	NodeClass Class       `yaml:"nodeClass,omitempty"` // optional
+	// Pending pods rejected by the scheduler, optionally with a reason found in
+	// the PodScheduled condition or a FailedScheduling event
+	Unschedulable bool                     `yaml:"unschedulable,omitempty"`
+	Reason        string                   `yaml:"reason,omitempty"` // optional
+	Delay         time.Duration            `yaml:"within,omitempty"` // eventually, within this duration
+	Duration      time.Duration            `yaml:"for,omitempty"`    // consistently, for this duration
+	GVK           *schema.GroupVersionKind `yaml:"api,omitempty"`
 }
 
 type CreateStep struct {
-	Count    uint64
-	Class    Class
-	Object   Object
-	YamlPath string
+	Count    uint64 `yaml:"count"`
+	Class    Class  `yaml:"class,omitempty"`
+	Object   Object `yaml:"object,omitempty"`
+	YamlPath string `yaml:"path,omitempty"`
 }
 
 type ChangeStep struct {
-	Count        uint64
-	Class        Class
-	Object       Object
-	FromPodPhase v1.PodPhase
-	ToPodPhase   v1.PodPhase
+	Count        uint64      `yaml:"count"`
+	Class        Class       `yaml:"class"`
+	Object       Object      `yaml:"object"`
+	FromPodPhase v1.PodPhase `yaml:"from"`
+	ToPodPhase   v1.PodPhase `yaml:"to"`
 }
 
 type DeleteStep struct {
-	Count    uint64
-	Class    Class
-	Object   Object
-	YamlPath string
+	Count    uint64 `yaml:"count"`
+	Class    Class  `yaml:"class,omitempty"`
+	Object   Object `yaml:"object,omitempty"`
+	YamlPath string `yaml:"path,omitempty"`
 }
 
 type Verb string
@@ -15,24 +15,21 @@ func Test_parseAssertStep(t *testing.T) {
 
 	cases := []struct {
 		desc           string
-		predicate      []string
-		apiAssert      bool
+		raw            string
 		expectedAssert *AssertStep
 		err            error
 	}{
 		{
-			desc:      "<object>",
-			predicate: []string{"pods"},
-			apiAssert: false,
+			desc: "<object>",
+			raw:  "assert 0 pods",
 			expectedAssert: &AssertStep{
 				Object: Pod,
 			},
 			err: nil,
 		},
 		{
-			desc:      "<class> <object>",
-			predicate: []string{"4-cpu", "pods"},
-			apiAssert: false,
+			desc: "<class> <object>",
+			raw:  "assert 0 4-cpu pods",
 			expectedAssert: &AssertStep{
 				Class:  Class("4-cpu"),
 				Object: Pod,
@@ -40,9 +37,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<class> <object>",
-			predicate: []string{"4-cpu", "nodes"},
-			apiAssert: false,
+			desc: "<class> <object>",
+			raw:  "assert 0 4-cpu nodes",
 			expectedAssert: &AssertStep{
 				Class:  Class("4-cpu"),
 				Object: Node,
@@ -50,9 +46,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<class> <object> <is> <phase>",
-			predicate: []string{"4-cpu", "pod", "is", "Running"},
-			apiAssert: false,
+			desc: "<class> <object> <is> <phase>",
+			raw:  "assert 0 4-cpu pod is Running",
 			expectedAssert: &AssertStep{
 				Class:    Class("4-cpu"),
 				Object:   Pod,
@@ -61,9 +56,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<object> <is> <phase>",
-			predicate: []string{"pod", "is", "Running"},
-			apiAssert: false,
+			desc: "<object> <is> <phase>",
+			raw:  "assert 0 pod is Running",
 			expectedAssert: &AssertStep{
 				Object:   Pod,
 				PodPhase: v1.PodRunning,
@@ -71,9 +65,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<object> <within> <duration>",
-			predicate: []string{"pod", "within", "4s"},
-			apiAssert: false,
+			desc: "<object> <within> <duration>",
+			raw:  "assert 0 pod within 4s",
 			expectedAssert: &AssertStep{
 				Object: Pod,
 				Delay:  4 * time.Second,
@@ -81,9 +74,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<class> <object> <within> <duration>",
-			predicate: []string{"4-cpu", "pod", "within", "4s"},
-			apiAssert: false,
+			desc: "<class> <object> <within> <duration>",
+			raw:  "assert 0 4-cpu pod within 4s",
 			expectedAssert: &AssertStep{
 				Class:  Class("4-cpu"),
 				Object: Pod,
@@ -92,9 +84,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<class> <object> <is> <phase> <within> <duration>",
-			predicate: []string{"4-cpu", "pod", "is", "Running", "within", "4s"},
-			apiAssert: false,
+			desc: "<class> <object> <is> <phase> <within> <duration>",
+			raw:  "assert 0 4-cpu pod is Running within 4s",
 			expectedAssert: &AssertStep{
 				Class:    Class("4-cpu"),
 				Object:   Pod,
@@ -104,9 +95,82 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<version> <kind>",
-			predicate: []string{"v1", "Pod"},
-			apiAssert: true,
+			desc: "<class> <object> on <class> <object>",
+			raw:  "assert 0 1-cpu pods on small nodes",
+			expectedAssert: &AssertStep{
+				Class:     Class("1-cpu"),
+				Object:    Pod,
+				NodeClass: Class("small"),
+			},
+			err: nil,
+		},
+		{
+			desc: "<object> <is> <phase> on <class> <object> <within> <duration>",
+			raw:  "assert 0 pods are Running on small nodes within 5s",
+			expectedAssert: &AssertStep{
+				Object:    Pod,
+				PodPhase:  v1.PodRunning,
+				NodeClass: Class("small"),
+				Delay:     5 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			desc: "<class> <object> on <class> <object>",
+			raw:  "assert 0 1-cpu pods on large node",
+			expectedAssert: &AssertStep{
+				Class:     Class("1-cpu"),
+				Object:    Pod,
+				NodeClass: Class("large"),
+			},
+			err: nil,
+		},
+		{
+			desc: "<class> <object> <is> <phase> <for> <duration>",
+			raw:  "assert 0 1-cpu-6-gang pods are Running for 10s",
+			expectedAssert: &AssertStep{
+				Class:    Class("1-cpu-6-gang"),
+				Object:   Pod,
+				PodPhase: v1.PodRunning,
+				Duration: 10 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			desc: "<class> <object> <throughout> <duration>",
+			raw:  "assert 0 small nodes throughout 1m",
+			expectedAssert: &AssertStep{
+				Class:    Class("small"),
+				Object:   Node,
+				Duration: time.Minute,
+			},
+			err: nil,
+		},
+		{
+			desc: "<class> <object> <is> unschedulable",
+			raw:  "assert 0 4-cpu pods are unschedulable",
+			expectedAssert: &AssertStep{
+				Class:         Class("4-cpu"),
+				Object:        Pod,
+				Unschedulable: true,
+			},
+			err: nil,
+		},
+		{
+			desc: "<class> <object> <is> unschedulable with reason <reason> <within> <duration>",
+			raw:  `assert 0 4-cpu pods are unschedulable with reason "insufficient cpu" within 5s`,
+			expectedAssert: &AssertStep{
+				Class:         Class("4-cpu"),
+				Object:        Pod,
+				Unschedulable: true,
+				Reason:        "insufficient cpu",
+				Delay:         5 * time.Second,
+			},
+			err: nil,
+		},
+		{
+			desc: "<version> <kind>",
+			raw:  "assert api v1 Pod",
 			expectedAssert: &AssertStep{
 				GVK: &schema.GroupVersionKind{
 					Version: "v1",
@@ -116,9 +180,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<version> <kind> <group>",
-			predicate: []string{"v1", "Job", "batch"},
-			apiAssert: true,
+			desc: "<version> <kind> <group>",
+			raw:  "assert api v1 Job batch",
 			expectedAssert: &AssertStep{
 				GVK: &schema.GroupVersionKind{
 					Version: "v1",
@@ -129,9 +192,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<version> <kind> <within> <duration>",
-			predicate: []string{"v1", "Pod", "within", "4s"},
-			apiAssert: true,
+			desc: "<version> <kind> <within> <duration>",
+			raw:  "assert api v1 Pod within 4s",
 			expectedAssert: &AssertStep{
 				GVK: &schema.GroupVersionKind{
 					Version: "v1",
@@ -142,9 +204,8 @@ func Test_parseAssertStep(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc:      "<version> <kind> <group> <within> <duration>",
-			predicate: []string{"v1", "Job", "batch", "within", "4s"},
-			apiAssert: true,
+			desc: "<version> <kind> <group> <within> <duration>",
+			raw:  "assert api v1 Job batch within 4s",
 			expectedAssert: &AssertStep{
 				GVK: &schema.GroupVersionKind{
 					Version: "v1",
@@ -155,105 +216,150 @@ func Test_parseAssertStep(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			desc: "<version> <kind> <for> <duration>",
+			raw:  "assert api v1 Pod for 4s",
+			expectedAssert: &AssertStep{
+				GVK: &schema.GroupVersionKind{
+					Version: "v1",
+					Kind:    "Pod",
+				},
+				Duration: 4 * time.Second,
+			},
+			err: nil,
+		},
 
 		// Negative tests
 		{
 			desc:           "<object>, invalid object",
-			predicate:      []string{"crd"},
+			raw:            "assert 0 crd",
 			expectedAssert: nil,
-			err:            fmt.Errorf("object must be either `node` or `pod`: (found `crd`)"),
-			apiAssert:      false,
+			err:            fmt.Errorf(`column 13: expected <object> ("pod[s]" or "node[s]"), found end of step`),
 		},
 		{
 			desc:           "<class> <object>, invalid object",
-			predicate:      []string{"4-cpu", "crd"},
+			raw:            "assert 0 4-cpu crd",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("object must be either `node` or `pod`: (found `crd`)"),
+			err:            fmt.Errorf(`column 16: expected <object> ("pod[s]" or "node[s]"), found "crd"`),
 		},
 		{
 			desc:           "<class> <object> <is> <phase>, Invalid phase",
-			predicate:      []string{"4-cpu", "pod", "is", "Foo"},
+			raw:            "assert 0 4-cpu pod is Foo",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("phase must be one of %s, %s, %s, %s or %s: (found `Foo`)", v1.PodPending, v1.PodRunning, v1.PodSucceeded, v1.PodFailed, v1.PodUnknown),
+			err:            fmt.Errorf(`column 23: expected <phase> (Pending, Running, Succeeded, Failed or Unknown), found "Foo"`),
 		},
 		{
 			desc:           "<object> <is> <phase>, invalid phase",
-			predicate:      []string{"pod", "is", "Foo"},
+			raw:            "assert 0 pod is Foo",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("phase must be one of %s, %s, %s, %s or %s: (found `Foo`)", v1.PodPending, v1.PodRunning, v1.PodSucceeded, v1.PodFailed, v1.PodUnknown),
+			err:            fmt.Errorf(`column 17: expected <phase> (Pending, Running, Succeeded, Failed or Unknown), found "Foo"`),
 		},
 		{
 			desc:           "<object> <within> <duration>, invalid duration",
-			predicate:      []string{"pod", "within", "foo"},
+			raw:            "assert 0 pod within foo",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 21: expected <duration> (e.g. "500ms" or "5s"), found "foo"`),
 		},
 		{
 			desc:           "<class> <object> <within> <duration>, invalid count",
-			predicate:      []string{"4-cpu", "pod", "within", "foo"},
+			raw:            "assert 0 4-cpu pod within foo",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 27: expected <duration> (e.g. "500ms" or "5s"), found "foo"`),
 		},
 		{
 			desc:           "<class> <object> <is> <phase> <within> <duration>, invalid count",
-			predicate:      []string{"4-cpu", "pod", "is", "Running", "within", "foo"},
+			raw:            "assert 0 4-cpu pod is Running within foo",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 38: expected <duration> (e.g. "500ms" or "5s"), found "foo"`),
 		},
 		{
 			desc:           "<class> <is> <phase> <within> <duration>, no object",
-			predicate:      []string{"4-cpu", "is", "Running", "within", "4s"},
+			raw:            "assert 0 4-cpu is Running within 4s",
 			expectedAssert: nil,
-			apiAssert:      false,
-			err:            fmt.Errorf("object must be either `node` or `pod`: (found `i`)"),
+			err:            fmt.Errorf(`column 16: expected <object> ("pod[s]" or "node[s]"), found "is"`),
+		},
+		{
+			desc:           "<class> <object> on <class>, no object",
+			raw:            "assert 0 1-cpu pods on small",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 29: expected "node[s]", found end of step`),
+		},
+		{
+			desc:           "<class> <object> on <class> <object>, placed on pods",
+			raw:            "assert 0 1-cpu pods on small pods",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 30: expected "node[s]", found "pods"`),
+		},
+		{
+			desc:           "<class> <object> on <class> <object>, nodes on nodes",
+			raw:            "assert 0 small nodes on large nodes",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 22: expected end of step or <duration>, found "on"`),
+		},
+		{
+			desc:           "<object> <for> <duration>, invalid duration",
+			raw:            "assert 0 pod for foo",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 18: expected <duration> (e.g. "500ms" or "5s"), found "foo"`),
+		},
+		{
+			desc:           "<class> <object> <is> unschedulable with reason, no reason",
+			raw:            "assert 0 4-cpu pods are unschedulable with reason within 5s",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 51: expected <reason>, found "within"`),
+		},
+		{
+			desc:           "<class> <object> <is> unschedulable, nodes",
+			raw:            "assert 0 small nodes are unschedulable",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 26: expected <phase> (Pending, Running, Succeeded, Failed or Unknown), found "unschedulable"`),
+		},
+		{
+			desc:           "<class> <object> <is> unschedulable on <class> <object>",
+			raw:            "assert 0 4-cpu pods are unschedulable on small nodes",
+			expectedAssert: nil,
+			err:            fmt.Errorf(`column 39: expected end of step or <duration>, found "on"`),
 		},
 		{
 			desc:           "<version> <kind> <group>, two missing",
-			predicate:      []string{"v1"},
+			raw:            "assert api v1",
 			expectedAssert: nil,
-			apiAssert:      true,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 14: expected <kind>, found end of step`),
 		},
 		{
 			desc:           "<version> <kind> <group> <within> <duration>, two missing",
-			predicate:      []string{"api", "within", "4s"},
+			raw:            "assert api api within 4s",
 			expectedAssert: nil,
-			apiAssert:      true,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 16: expected <kind>, found "within"`),
 		},
 		{
 			desc:           "<version> <kind> <within> <duration>, wrong duration",
-			predicate:      []string{"v1", "Pod", "within", "foo"},
+			raw:            "assert api v1 Pod within foo",
 			expectedAssert: nil,
-			apiAssert:      true,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 26: expected <duration> (e.g. "500ms" or "5s"), found "foo"`),
 		},
 		{
 			desc:           "<version> <kind> <group> <within> <duration>, one missing",
-			predicate:      []string{"Job", "v1", "Batch", "4s"},
+			raw:            "assert api Job v1 Batch 4s",
 			expectedAssert: nil,
-			apiAssert:      true,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 25: expected end of step, found "4s"`),
 		},
 		{
 			desc:           "<version> <kind> <group> <within> <duration>, wrong duration",
-			predicate:      []string{"v1", "Job", "batch", "within", "foo"},
+			raw:            "assert api v1 Job batch within foo",
 			expectedAssert: nil,
-			apiAssert:      true,
-			err:            fmt.Errorf("syntax: assert ( <count> [<class>] <object> [<is> <phase>] | api <version> <kind> [<group>] ) [<within> <duration>]"),
+			err:            fmt.Errorf(`column 32: expected <duration> (e.g. "500ms" or "5s"), found "foo"`),
 		},
 	}
 
 	for _, c := range cases {
 		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
 
-		actualAssert, err := parseAssertStep(uint64(0), c.predicate, c.apiAssert)
+		var actualAssert *AssertStep
+		step, err := ParseStep(c.raw)
+		if step != nil {
+			actualAssert = step.Assert
+		}
 		if c.err != nil {
 			if err == nil || err.Error() != c.err.Error() {
 				t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
@@ -266,3 +372,286 @@ func Test_parseAssertStep(t *testing.T) {
 		}
 	}
 }
+
+func Test_ScenarioFromBytes(t *testing.T) {
+	data := []byte(`
+name: "mixed steps"
+version: 1
+steps:
+- "create 1 large node"
+- create: {count: 3, class: 1-cpu, object: pods}
+- assert: {count: 3, class: 1-cpu, object: pod, phase: running, within: 5s}
+- assert: {count: 3, class: 1-cpu, object: pod, nodeClass: large, for: 500ms}
+- assert: {api: {version: v1, kind: Job, group: batch}, within: 10s}
+- change: {count: 1, class: 1-cpu, object: pod, from: Running, to: Failed}
+- delete: {count: 1, path: job.yml}
+`)
+	expectedSteps := []*Step{
+		{Verb: Create, Create: &CreateStep{Count: 1, Class: Class("large"), Object: Node}},
+		{Verb: Create, Create: &CreateStep{Count: 3, Class: Class("1-cpu"), Object: Pod}},
+		{Verb: Assert, Assert: &AssertStep{Count: 3, Class: Class("1-cpu"), Object: Pod, PodPhase: v1.PodRunning, Delay: 5 * time.Second}},
+		{Verb: Assert, Assert: &AssertStep{Count: 3, Class: Class("1-cpu"), Object: Pod, NodeClass: Class("large"), Duration: 500 * time.Millisecond}},
+		{Verb: Assert, Assert: &AssertStep{GVK: &schema.GroupVersionKind{Version: "v1", Kind: "Job", Group: "batch"}, Delay: 10 * time.Second}},
+		{Verb: Change, Change: &ChangeStep{Count: 1, Class: Class("1-cpu"), Object: Pod, FromPodPhase: v1.PodRunning, ToPodPhase: v1.PodFailed}},
+		{Verb: Delete, Delete: &DeleteStep{Count: 1, YamlPath: "job.yml"}},
+	}
+
+	scenario, err := ScenarioFromBytes(data)
+	if err != nil {
+		t.Fatalf("expected err to be nil, but got: %s", err)
+	}
+	if !reflect.DeepEqual(expectedSteps, scenario.Steps) {
+		t.Fatalf("expected steps: %v, but got %v", expectedSteps, scenario.Steps)
+	}
+
+	// Structured steps are described in the step grammar and parse back
+	// to the same step.
+	for i, raw := range scenario.RawSteps {
+		step, err := ParseStep(raw.String())
+		if err != nil {
+			t.Fatalf("(step %d) expected `%s` to parse, but got: %s", i, raw, err)
+		}
+		if !reflect.DeepEqual(scenario.Steps[i], step) {
+			t.Fatalf("(step %d) expected step: %v, but got %v", i, scenario.Steps[i], step)
+		}
+	}
+}
+
+func Test_ScenarioFromBytes_invalidStructuredSteps(t *testing.T) {
+	cases := []struct {
+		desc string
+		step string
+		err  error
+	}{
+		{
+			desc: "two verbs",
+			step: "{create: {count: 1, class: large, object: node}, delete: {count: 1, class: large, object: node}}",
+			err:  fmt.Errorf("unable to parse: step [0]: a step needs exactly one of assert, create, change or delete (found 2) (input: `create 1 large node`)"),
+		},
+		{
+			desc: "invalid object",
+			step: "{assert: {count: 1, object: crd}}",
+			err:  fmt.Errorf("unable to parse: step [0]: object must be either `node` or `pod`: (found `crd`) (input: `assert 1 crd`)"),
+		},
+		{
+			desc: "invalid phase",
+			step: "{change: {count: 1, class: 1-cpu, object: pod, from: Running, to: Done}}",
+			err:  fmt.Errorf("unable to parse: step [0]: expected <phase> (Pending, Running, Succeeded, Failed or Unknown), found `Done` (input: `change 1 1-cpu pod from Running to Done`)"),
+		},
+		{
+			desc: "within and for",
+			step: "{assert: {count: 1, object: pod, within: 1s, for: 1s}}",
+			err:  fmt.Errorf("unable to parse: step [0]: an assert cannot have both `within` and `for` (input: `assert 1 pod within 1s`)"),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+
+		_, err := ScenarioFromBytes([]byte("steps:\n- " + c.step + "\n"))
+		if err == nil || err.Error() != c.err.Error() {
+			t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
+		}
+	}
+}
+
+func Test_ScenarioFromBytes_blocks(t *testing.T) {
+	data := []byte(`
+steps:
+- "create 1 large node"
+- repeat: 2
+  steps:
+  - "create 10 1-cpu pods"
+  - forEach: phase
+    in: [Running, Succeeded]
+    steps:
+    - assert: {count: 10, class: 1-cpu, object: pods, phase: "${phase}"}
+  - "delete 10 1-cpu pods"
+`)
+	assert := func(phase v1.PodPhase, iteration string) *Step {
+		return &Step{Verb: Assert, Assert: &AssertStep{Count: 10, Class: Class("1-cpu"), Object: Pod, PodPhase: phase}, Iteration: iteration}
+	}
+	create := &Step{Verb: Create, Create: &CreateStep{Count: 10, Class: Class("1-cpu"), Object: Pod}}
+	del := &Step{Verb: Delete, Delete: &DeleteStep{Count: 10, Class: Class("1-cpu"), Object: Pod}}
+	expectedSteps := []*Step{
+		{Verb: Create, Create: &CreateStep{Count: 1, Class: Class("large"), Object: Node}},
+	}
+	for _, iteration := range []string{"iteration 1/2", "iteration 2/2"} {
+		c, d := *create, *del
+		c.Iteration, d.Iteration = iteration, iteration
+		expectedSteps = append(expectedSteps,
+			&c,
+			assert(v1.PodRunning, iteration+", phase=Running"),
+			assert(v1.PodSucceeded, iteration+", phase=Succeeded"),
+			&d,
+		)
+	}
+
+	scenario, err := ScenarioFromBytes(data)
+	if err != nil {
+		t.Fatalf("expected err to be nil, but got: %s", err)
+	}
+	if !reflect.DeepEqual(expectedSteps, scenario.Steps) {
+		t.Fatalf("expected steps: %v, but got %v", expectedSteps, scenario.Steps)
+	}
+}
+
+func Test_ScenarioFromBytes_invalidBlocks(t *testing.T) {
+	cases := []struct {
+		desc  string
+		steps string
+		err   error
+	}{
+		{
+			desc:  "repeat and forEach",
+			steps: "- {repeat: 2, forEach: x, in: [a], steps: [\"create 1 ${x} node\"]}",
+			err:   fmt.Errorf("unable to parse: step [0]: a block needs exactly one of repeat or forEach (input: `for each x in [a]`)"),
+		},
+		{
+			desc:  "forEach without values",
+			steps: "- {forEach: x, steps: [\"create 1 ${x} node\"]}",
+			err:   fmt.Errorf("unable to parse: step [0]: forEach needs at least one value in `in` (input: `for each x in []`)"),
+		},
+		{
+			desc:  "empty block",
+			steps: "- {repeat: 2, steps: []}",
+			err:   fmt.Errorf("unable to parse: step [0]: a block needs at least one step (input: `repeat 2 times`)"),
+		},
+		{
+			desc:  "undefined variable",
+			steps: "- {forEach: x, in: [a], steps: [\"create 1 large node\", \"create 1 ${y} node\"]}",
+			err:   fmt.Errorf("unable to parse: step [0][1] (x=a): undefined variable `y` (input: `create 1 ${y} node`)"),
+		},
+		{
+			desc:  "invalid step in a nested block",
+			steps: "- {repeat: 1, steps: [{forEach: o, in: [pod, crd], steps: [\"assert 1 ${o}\"]}]}",
+			err:   fmt.Errorf("unable to parse: step [0][0][0] (iteration 1/1, o=crd): column 13: expected <object> (\"pod[s]\" or \"node[s]\"), found end of step in `assert 1 crd` (input: `assert 1 ${o}`)"),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+
+		_, err := ScenarioFromBytes([]byte("steps:\n" + c.steps + "\n"))
+		if err == nil || err.Error() != c.err.Error() {
+			t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
+		}
+	}
+}
+
+func Test_ScenarioFromBytesWithParams(t *testing.T) {
+	data := []byte(`
+params:
+  nodes: 10
+  class: large
+  rounds: 1
+steps:
+- "create ${nodes} ${class} nodes"
+- create: {count: "${nodes*2}", class: 1-cpu, object: pods}
+- repeat: ${rounds}
+  steps:
+  - assert:
+      count: ${nodes*2}
+      class: 1-cpu
+      object: pods
+      phase: Running
+`)
+	expected := func(nodes uint64, class Class) []*Step {
+		return []*Step{
+			{Verb: Create, Create: &CreateStep{Count: nodes, Class: class, Object: Node}},
+			{Verb: Create, Create: &CreateStep{Count: nodes * 2, Class: Class("1-cpu"), Object: Pod}},
+			{Verb: Assert, Assert: &AssertStep{Count: nodes * 2, Class: Class("1-cpu"), Object: Pod, PodPhase: v1.PodRunning}, Iteration: "iteration 1/1"},
+		}
+	}
+
+	cases := []struct {
+		desc          string
+		overrides     map[string]string
+		expectedSteps []*Step
+		err           error
+	}{
+		{
+			desc:          "defaults",
+			overrides:     nil,
+			expectedSteps: expected(10, Class("large")),
+		},
+		{
+			desc:          "overrides",
+			overrides:     map[string]string{"nodes": "1000", "class": "small"},
+			expectedSteps: expected(1000, Class("small")),
+		},
+		{
+			desc:      "unknown parameter",
+			overrides: map[string]string{"pods": "1000"},
+			err:       fmt.Errorf("unknown parameter `pods`, the scenario declares: class, nodes, rounds"),
+		},
+		{
+			desc:      "negative count",
+			overrides: map[string]string{"nodes": "-1"},
+			err:       fmt.Errorf("unable to parse: step [0]: column 8: expected <count>, found \"-1\" in `create -1 large nodes` (input: `create ${nodes} ${class} nodes`)"),
+		},
+		{
+			desc:      "invalid repeat",
+			overrides: map[string]string{"rounds": "many"},
+			err:       fmt.Errorf("unable to parse: step [2]: repeat must be a count: (found `many`) (input: `repeat ${rounds} times`)"),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+
+		scenario, err := ScenarioFromBytesWithParams(data, c.overrides)
+		if c.err != nil {
+			if err == nil || err.Error() != c.err.Error() {
+				t.Fatalf("(case: %s) expected error: %s, but got %s", c.desc, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(case: %s) expected err to be nil, but got: %s", c.desc, err)
+		}
+		if !reflect.DeepEqual(c.expectedSteps, scenario.Steps) {
+			t.Fatalf("(case: %s) expected steps: %v, but got %v", c.desc, c.expectedSteps, scenario.Steps)
+		}
+	}
+}
+
+func Test_ScenarioFromBytes_sections(t *testing.T) {
+	data := []byte(`
+params:
+  nodes: 2
+setup:
+- "create ${nodes} large nodes"
+steps:
+- "create 1 1-cpu pod"
+teardown:
+- "delete ${nodes} large nodes"
+onFailure:
+- "assert 0 1-cpu pods are Pending"
+`)
+	scenario, err := ScenarioFromBytes(data)
+	if err != nil {
+		t.Fatalf("expected err to be nil, but got: %s", err)
+	}
+	expected := map[string][]*Step{
+		"setup":     {{Verb: Create, Create: &CreateStep{Count: 2, Class: Class("large"), Object: Node}}},
+		"steps":     {{Verb: Create, Create: &CreateStep{Count: 1, Class: Class("1-cpu"), Object: Pod}}},
+		"teardown":  {{Verb: Delete, Delete: &DeleteStep{Count: 2, Class: Class("large"), Object: Node}}},
+		"onFailure": {{Verb: Assert, Assert: &AssertStep{Count: 0, Class: Class("1-cpu"), Object: Pod, PodPhase: v1.PodPending}}},
+	}
+	actual := map[string][]*Step{
+		"setup":     scenario.Setup,
+		"steps":     scenario.Steps,
+		"teardown":  scenario.Teardown,
+		"onFailure": scenario.OnFailure,
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected sections: %v, but got %v", expected, actual)
+	}
+
+	_, err = ScenarioFromBytes([]byte("steps: []\nteardown:\n- \"delete 1 large\"\n"))
+	expectedErr := "unable to parse: teardown step [0]: column 15: expected <object> (\"pod[s]\" or \"node[s]\"), found end of step (input: `delete 1 large`)"
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error: %s, but got %s", expectedErr, err)
+	}
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Validate checks a structured step the way ParseStep checks a step string.
+// It sets the verb from the step that is present and normalizes object
+// names and phases, so that "Pods" and "running" are accepted like in the
+// step grammar.
+func (s *Step) Validate() error {
+	verbs := []Verb{}
+	if s.Assert != nil {
+		verbs = append(verbs, Assert)
+	}
+	if s.Create != nil {
+		verbs = append(verbs, Create)
+	}
+	if s.Change != nil {
+		verbs = append(verbs, Change)
+	}
+	if s.Delete != nil {
+		verbs = append(verbs, Delete)
+	}
+	if len(verbs) != 1 {
+		return fmt.Errorf("a step needs exactly one of %s, %s, %s or %s (found %d)", Assert, Create, Change, Delete, len(verbs))
+	}
+	if s.Verb != "" && s.Verb != verbs[0] {
+		return fmt.Errorf("verb `%s` does not match the %s step", s.Verb, verbs[0])
+	}
+	s.Verb = verbs[0]
+
+	switch s.Verb {
+	case Assert:
+		return s.Assert.validate()
+	case Create:
+		return validateTarget(&s.Create.Class, &s.Create.Object, s.Create.YamlPath)
+	case Change:
+		return s.Change.validate()
+	case Delete:
+		return validateTarget(&s.Delete.Class, &s.Delete.Object, s.Delete.YamlPath)
+	}
+	return nil
+}
+
+func (a *AssertStep) validate() error {
+	if a.GVK != nil {
+		if a.GVK.Version == "" || a.GVK.Kind == "" {
+			return fmt.Errorf("api assert needs a version and a kind")
+		}
+		if a.Object != "" || a.Class != "" {
+			return fmt.Errorf("api assert cannot have an object or class")
+		}
+	} else {
+		obj, err := normalizeObject(a.Object)
+		if err != nil {
+			return err
+		}
+		a.Object = obj
+	}
+	if a.PodPhase != "" {
+		phase, err := normalizePhase(a.PodPhase)
+		if err != nil {
+			return err
+		}
+		a.PodPhase = phase
+	}
+	if a.Unschedulable && (a.Object != Pod || a.PodPhase != "") {
+		return fmt.Errorf("only pods without a phase can be asserted unschedulable")
+	}
+	if a.Reason != "" && !a.Unschedulable {
+		return fmt.Errorf("a reason can only be given for unschedulable pods")
+	}
+	if a.NodeClass != "" && (a.Object != Pod || a.Unschedulable) {
+		return fmt.Errorf("only scheduled pods can be asserted on a node class")
+	}
+	if a.Delay != 0 && a.Duration != 0 {
+		return fmt.Errorf("an assert cannot have both `within` and `for`")
+	}
+	return nil
+}
+
+func (c *ChangeStep) validate() error {
+	if c.Class == "" {
+		return fmt.Errorf("change needs a class")
+	}
+	obj, err := normalizeObject(c.Object)
+	if err != nil {
+		return err
+	}
+	c.Object = obj
+	if c.FromPodPhase, err = normalizePhase(c.FromPodPhase); err != nil {
+		return err
+	}
+	if c.ToPodPhase, err = normalizePhase(c.ToPodPhase); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Create and delete steps target either a class of objects or the objects in
+// a yaml file.
+func validateTarget(class *Class, object *Object, path string) error {
+	if path != "" {
+		if *class != "" || *object != "" {
+			return fmt.Errorf("a step with a path cannot have an object or class")
+		}
+		return nil
+	}
+	if *class == "" {
+		return fmt.Errorf("a step needs either a path or a class and an object")
+	}
+	obj, err := normalizeObject(*object)
+	if err != nil {
+		return err
+	}
+	*object = obj
+	return nil
+}
+
+func normalizeObject(o Object) (Object, error) {
+	canonical := Object(strings.TrimSuffix(strings.ToLower(string(o)), "s"))
+	switch canonical {
+	case Pod, Node:
+		return canonical, nil
+	}
+	return o, fmt.Errorf("object must be either `node` or `pod`: (found `%s`)", o)
+}
+
+func normalizePhase(p v1.PodPhase) (v1.PodPhase, error) {
+	for _, phase := range phases {
+		if strings.EqualFold(string(p), string(phase)) {
+			return phase, nil
+		}
+	}
+	return p, fmt.Errorf("expected %s, found `%s`", expectedPhase, p)
+}
+
+// String renders the step in the step grammar, such that ParseStep returns
+// an equal step.
+func (s *Step) String() string {
+	var words []string
+	switch {
+	case s.Assert != nil:
+		words = s.Assert.words()
+	case s.Create != nil:
+		words = append([]string{string(Create)}, targetWords(s.Create.Count, s.Create.Class, s.Create.Object, s.Create.YamlPath)...)
+	case s.Change != nil:
+		c := s.Change
+		words = []string{string(Change), strconv.FormatUint(c.Count, 10), quoteWord(string(c.Class)), objectWord(c.Object, c.Count),
+			"from", string(c.FromPodPhase), "to", string(c.ToPodPhase)}
+	case s.Delete != nil:
+		words = append([]string{string(Delete)}, targetWords(s.Delete.Count, s.Delete.Class, s.Delete.Object, s.Delete.YamlPath)...)
+	}
+	return strings.Join(words, " ")
+}
+
+func (a *AssertStep) words() []string {
+	words := []string{string(Assert)}
+	if a.GVK != nil {
+		words = append(words, "api", quoteWord(a.GVK.Version), quoteWord(a.GVK.Kind))
+		if a.GVK.Group != "" {
+			words = append(words, quoteWord(a.GVK.Group))
+		}
+	} else {
+		words = append(words, strconv.FormatUint(a.Count, 10))
+		if a.Class != "" {
+			words = append(words, quoteWord(string(a.Class)))
+		}
+		words = append(words, objectWord(a.Object, a.Count))
+		is := "are"
+		if a.Count == 1 {
+			is = "is"
+		}
+		if a.Unschedulable {
+			words = append(words, is, "unschedulable")
+			if a.Reason != "" {
+				words = append(words, "with", "reason", quote(a.Reason))
+			}
+		} else if a.PodPhase != "" {
+			words = append(words, is, string(a.PodPhase))
+		}
+		if a.NodeClass != "" {
+			words = append(words, "on", quoteWord(string(a.NodeClass)), "nodes")
+		}
+	}
+	if a.Delay != 0 {
+		words = append(words, "within", a.Delay.String())
+	} else if a.Duration != 0 {
+		words = append(words, "for", a.Duration.String())
+	}
+	return words
+}
+
+func targetWords(count uint64, class Class, object Object, path string) []string {
+	if path != "" {
+		instance := "instances"
+		if count == 1 {
+			instance = "instance"
+		}
+		return []string{strconv.FormatUint(count, 10), instance, "of", quoteWord(path)}
+	}
+	return []string{strconv.FormatUint(count, 10), quoteWord(string(class)), objectWord(object, count)}
+}
+
+func objectWord(object Object, count uint64) string {
+	if canonical, err := normalizeObject(object); err == nil {
+		object = canonical
+	}
+	if count == 1 {
+		return string(object)
+	}
+	return string(object) + "s"
+}
+
+// Quotes a word if it would otherwise not be read back as a single,
+// non-keyword word.
+func quoteWord(w string) string {
+	if w == "" || keywords[strings.ToLower(w)] || strings.HasPrefix(w, `"`) || strings.IndexFunc(w, unicode.IsSpace) >= 0 {
+		return quote(w)
+	}
+	return w
+}
+
+func quote(w string) string {
+	w = strings.Replace(w, `\`, `\\`, -1)
+	w = strings.Replace(w, `"`, `\"`, -1)
+	return `"` + w + `"`
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Validate checks a scenario against the node and pod configs without
+// contacting an API server, and returns every problem found:
+// - node and pod classes that are not in the configs
+// - yaml files that do not resolve from the working directory or do not parse
+// - node resource quantities that do not parse
+// - asserts, changes and deletes of more nodes or pods than can exist
+//
+// Either config may be nil. Steps that create nodes or pods then need it,
+// but asserts are not checked against it, since the nodes or pods may come
+// from elsewhere. Counts are only checked for the classes the scenario
+// creates itself, assuming it creates all nodes and pods of those classes.
+func Validate(scenario *Scenario, nodeConfig *NodeConfig, podConfig *PodConfig) []error {
+	v := &validator{
+		workingDir:    scenario.WorkingDir,
+		nodeConfig:    nodeConfig,
+		podConfig:     podConfig,
+		objects:       map[string]bool{},
+		objectClasses: map[Class]bool{},
+		counts:        map[Object]map[Class]uint64{Node: {}, Pod: {}},
+	}
+	v.checkNodeResources()
+
+	sections := []struct {
+		name  string
+		steps []*Step
+		// onFailure may run after any failed step, so counts are not
+		// tracked through it
+		counted bool
+	}{
+		{"setup", scenario.Setup, true},
+		{"", scenario.Steps, true},
+		{"onFailure", scenario.OnFailure, false},
+		{"teardown", scenario.Teardown, true},
+	}
+	for _, section := range sections {
+		for _, step := range section.steps {
+			v.checkObjects(step)
+		}
+	}
+	for _, section := range sections {
+		for _, step := range section.steps {
+			if section.counted {
+				v.trackCreated(step)
+			}
+		}
+	}
+	for _, section := range sections {
+		for i, step := range section.steps {
+			v.checkStep(stepName(section.name, i, step), step, section.counted)
+		}
+	}
+	return v.problems
+}
+
+type validator struct {
+	workingDir string
+	nodeConfig *NodeConfig
+	podConfig  *PodConfig
+	// Yaml paths already checked
+	objects map[string]bool
+	// Pod classes labeled in the objects created from yaml, e.g. by the
+	// pod template of a deployment
+	objectClasses map[Class]bool
+	// The most nodes and pods of each class that can exist at the current
+	// step, for the classes the scenario creates
+	counts   map[Object]map[Class]uint64
+	problems []error
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Errorf(format, args...))
+}
+
+func (v *validator) checkNodeResources() {
+	if v.nodeConfig == nil {
+		return
+	}
+	for _, class := range v.nodeConfig.NodeClasses {
+		for _, kind := range []struct {
+			name      string
+			resources map[string]string
+		}{
+			{"capacity", class.Resources.Capacity},
+			{"allocatable", class.Resources.Allocatable},
+		} {
+			names := []string{}
+			for name := range kind.resources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if _, err := resource.ParseQuantity(kind.resources[name]); err != nil {
+					v.addf("node class `%s`: invalid %s %s `%s`: %s", class.Name, kind.name, name, kind.resources[name], err.Error())
+				}
+			}
+		}
+	}
+}
+
+// Checks that the yaml files of create and delete steps resolve and parse,
+// and collects the pod classes labeled in them.
+func (v *validator) checkObjects(step *Step) {
+	var yamlPath string
+	switch {
+	case step.Create != nil:
+		yamlPath = step.Create.YamlPath
+	case step.Delete != nil:
+		yamlPath = step.Delete.YamlPath
+	}
+	if yamlPath == "" {
+		return
+	}
+	fullPath := path.Join(v.workingDir, yamlPath)
+	if v.objects[fullPath] {
+		return
+	}
+	v.objects[fullPath] = true
+
+	data, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		v.addf("%s: %s", yamlPath, err.Error())
+		return
+	}
+	object := map[string]interface{}{}
+	if err := k8syaml.NewYAMLToJSONDecoder(bytes.NewReader(data)).Decode(&object); err != nil {
+		v.addf("%s: unable to parse: %s", yamlPath, err.Error())
+		return
+	}
+	if kind, _ := object["kind"].(string); kind == "" {
+		v.addf("%s: the object has no kind", yamlPath)
+	}
+	if apiVersion, _ := object["apiVersion"].(string); apiVersion == "" {
+		v.addf("%s: the object has no apiVersion", yamlPath)
+	}
+	collectClasses(object, v.objectClasses)
+}
+
+// Collects the values of every np.class key in a decoded object.
+func collectClasses(node interface{}, classes map[Class]bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if class, ok := value.(string); ok && key == "np.class" {
+				classes[Class(class)] = true
+				continue
+			}
+			collectClasses(value, classes)
+		}
+	case []interface{}:
+		for _, value := range n {
+			collectClasses(value, classes)
+		}
+	}
+}
+
+// Starts counting the classes of nodes and pods the scenario creates,
+// except pod classes that objects created from yaml may add to.
+func (v *validator) trackCreated(step *Step) {
+	if step.Create == nil || step.Create.YamlPath != "" {
+		return
+	}
+	if !v.knownClass(step.Create.Object, step.Create.Class, true) {
+		return
+	}
+	if step.Create.Object == Pod && v.objectClasses[step.Create.Class] {
+		return
+	}
+	if counts, ok := v.counts[step.Create.Object]; ok {
+		counts[step.Create.Class] = 0
+	}
+}
+
+func (v *validator) checkStep(name string, step *Step, counted bool) {
+	switch {
+	case step.Assert != nil:
+		a := step.Assert
+		if a.GVK != nil {
+			return
+		}
+		if a.Class != "" && !v.knownClass(a.Object, a.Class, false) {
+			v.addf("%s: no %s class `%s` in the %s config", name, a.Object, a.Class, a.Object)
+		}
+		if a.NodeClass != "" && !v.knownClass(Node, a.NodeClass, false) {
+			v.addf("%s: no node class `%s` in the node config", name, a.NodeClass)
+		}
+		if max, ok := v.count(a.Object, a.Class); ok && counted && a.Count > max {
+			v.addf("%s: asserts %d %s %s, but at most %d can exist at this point", name, a.Count, a.Class, objectWord(a.Object, a.Count), max)
+		}
+	case step.Create != nil:
+		c := step.Create
+		if c.YamlPath != "" {
+			return
+		}
+		if !v.knownClass(c.Object, c.Class, true) {
+			if v.config(c.Object) {
+				v.addf("%s: no %s class `%s` in the %s config", name, c.Object, c.Class, c.Object)
+			} else {
+				v.addf("%s: creating %s needs a %s config", name, objectWord(c.Object, 2), c.Object)
+			}
+			return
+		}
+		if max, ok := v.count(c.Object, c.Class); ok && counted {
+			v.counts[c.Object][c.Class] = max + c.Count
+		}
+	case step.Change != nil:
+		c := step.Change
+		if !v.knownClass(c.Object, c.Class, false) {
+			v.addf("%s: no %s class `%s` in the %s config", name, c.Object, c.Class, c.Object)
+		}
+		if max, ok := v.count(c.Object, c.Class); ok && counted && c.Count > max {
+			v.addf("%s: changes %d %s %s, but at most %d can exist at this point", name, c.Count, c.Class, objectWord(c.Object, c.Count), max)
+		}
+	case step.Delete != nil:
+		d := step.Delete
+		if d.YamlPath != "" {
+			return
+		}
+		if !v.knownClass(d.Object, d.Class, false) {
+			v.addf("%s: no %s class `%s` in the %s config", name, d.Object, d.Class, d.Object)
+		}
+		if max, ok := v.count(d.Object, d.Class); ok && counted {
+			deleted := d.Count
+			if deleted > max {
+				v.addf("%s: deletes %d %s %s, but at most %d can exist at this point", name, d.Count, d.Class, objectWord(d.Object, d.Count), max)
+				deleted = max
+			}
+			v.counts[d.Object][d.Class] = max - deleted
+		}
+	}
+}
+
+func (v *validator) config(object Object) bool {
+	if object == Node {
+		return v.nodeConfig != nil
+	}
+	return v.podConfig != nil
+}
+
+// Whether the class is in the node or pod config, or labeled in an object
+// created from yaml. Without a config any class is accepted, unless the
+// class must be in the config for the step to create it.
+func (v *validator) knownClass(object Object, class Class, create bool) bool {
+	switch object {
+	case Node:
+		if v.nodeConfig == nil {
+			return !create
+		}
+		for _, c := range v.nodeConfig.NodeClasses {
+			if Class(c.Name) == class {
+				return true
+			}
+		}
+	case Pod:
+		if !create && v.objectClasses[class] {
+			return true
+		}
+		if v.podConfig == nil {
+			return !create
+		}
+		for _, c := range v.podConfig.PodClasses {
+			if Class(c.Name) == class {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the most nodes or pods of the class that can exist, and whether
+// the class is counted.
+func (v *validator) count(object Object, class Class) (uint64, bool) {
+	max, ok := v.counts[object][class]
+	return max, ok
+}
+
+// Names a step in validation problems, e.g. "setup step [2] `create 1 large node`".
+func stepName(section string, index int, step *Step) string {
+	name := fmt.Sprintf("step [%d]", index+1)
+	if section != "" {
+		name = section + " " + name
+	}
+	name += fmt.Sprintf(" `%s`", step.String())
+	if step.Iteration != "" {
+		name += fmt.Sprintf(" (%s)", step.Iteration)
+	}
+	return name
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_Validate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"deployment.yml": `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    metadata:
+      labels:
+        np.class: deployment-test
+`,
+		"broken.yml":   "kind: [",
+		"kindless.yml": "apiVersion: v1\n",
+	})
+	defer os.RemoveAll(dir)
+
+	nodeConfig, err := NodeConfigFromBytes([]byte(`
+nodeClasses:
+- name: large
+  resources:
+    allocatable:
+      cpu: "8"
+      memory: 128Gi
+- name: small
+  resources:
+    capacity:
+      cpu: 2x
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	podConfig, err := PodConfigFromBytes([]byte(`
+podClasses:
+- name: 1-cpu
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resourceProblem := "node class `small`: invalid capacity cpu `2x`: quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'"
+
+	cases := []struct {
+		desc       string
+		scenario   string
+		nodeConfig *NodeConfig
+		podConfig  *PodConfig
+		expected   []string
+	}{
+		{
+			desc: "valid scenario",
+			scenario: `
+setup:
+- "create 2 large nodes"
+steps:
+- "create 3 1-cpu pods"
+- "assert 2 large nodes"
+- "assert 3 1-cpu pods are Running on large nodes"
+- "change 3 1-cpu pods from Running to Succeeded"
+- "create 1 instance of deployment.yml"
+- "assert 2 deployment-test pods"
+- "assert 5 pods"
+teardown:
+- "delete 3 1-cpu pods"
+- "delete 2 large nodes"
+`,
+			nodeConfig: nodeConfig,
+			podConfig:  podConfig,
+			expected:   []string{resourceProblem},
+		},
+		{
+			desc: "unknown classes",
+			scenario: `
+steps:
+- "create 1 lage node"
+- "create 1 2-cpu pod"
+- "assert 1 1-cpu pod on lage nodes"
+- "change 1 2-cpu pod from Running to Failed"
+- "delete 1 lage node"
+`,
+			nodeConfig: nodeConfig,
+			podConfig:  podConfig,
+			expected: []string{
+				resourceProblem,
+				"step [1] `create 1 lage node`: no node class `lage` in the node config",
+				"step [2] `create 1 2-cpu pod`: no pod class `2-cpu` in the pod config",
+				"step [3] `assert 1 1-cpu pod on lage nodes`: no node class `lage` in the node config",
+				"step [4] `change 1 2-cpu pod from Running to Failed`: no pod class `2-cpu` in the pod config",
+				"step [5] `delete 1 lage node`: no node class `lage` in the node config",
+			},
+		},
+		{
+			desc: "creating without configs, asserting anything",
+			scenario: `
+steps:
+- "create 1 large node"
+- "create 1 1-cpu pod"
+- "assert 1 small node"
+`,
+			expected: []string{
+				"step [1] `create 1 large node`: creating nodes needs a node config",
+				"step [2] `create 1 1-cpu pod`: creating pods needs a pod config",
+			},
+		},
+		{
+			desc: "yaml files",
+			scenario: `
+steps:
+- "create 1 instance of missing.yml"
+- "create 1 instance of broken.yml"
+- "create 1 instance of kindless.yml"
+- "delete 1 instance of kindless.yml"
+`,
+			expected: []string{
+				"missing.yml: open " + filepath.Join(dir, "missing.yml") + ": no such file or directory",
+				"broken.yml: unable to parse: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+				"kindless.yml: the object has no kind",
+			},
+		},
+		{
+			desc: "impossible counts",
+			scenario: `
+setup:
+- "create 2 large nodes"
+steps:
+- "assert 3 large nodes"
+- "create 1 1-cpu pod"
+- "change 2 1-cpu pods from Running to Failed"
+- "delete 3 large nodes"
+- "assert 1 large node"
+onFailure:
+- "assert 9 large nodes"
+teardown:
+- "delete 2 1-cpu pods"
+`,
+			nodeConfig: nodeConfig,
+			podConfig:  podConfig,
+			expected: []string{
+				resourceProblem,
+				"step [1] `assert 3 large nodes`: asserts 3 large nodes, but at most 2 can exist at this point",
+				"step [3] `change 2 1-cpu pods from Running to Failed`: changes 2 1-cpu pods, but at most 1 can exist at this point",
+				"step [4] `delete 3 large nodes`: deletes 3 large nodes, but at most 2 can exist at this point",
+				"step [5] `assert 1 large node`: asserts 1 large node, but at most 0 can exist at this point",
+				"teardown step [1] `delete 2 1-cpu pods`: deletes 2 1-cpu pods, but at most 1 can exist at this point",
+			},
+		},
+		{
+			desc: "classes the scenario does not create are not counted",
+			scenario: `
+steps:
+- repeat: 2
+  steps:
+  - "delete 1 large node"
+`,
+			nodeConfig: &NodeConfig{NodeClasses: []NodeClass{{Name: "large"}}},
+			expected:   []string{},
+		},
+		{
+			desc: "classes created by the scenario are counted",
+			scenario: `
+steps:
+- forEach: n
+  in: ["1", "2"]
+  steps:
+  - "create 1 large node"
+  - "assert ${n} large nodes"
+  - "assert ${n+1} large nodes"
+`,
+			nodeConfig: &NodeConfig{NodeClasses: []NodeClass{{Name: "large"}}},
+			expected: []string{
+				"step [3] `assert 2 large nodes` (n=1): asserts 2 large nodes, but at most 1 can exist at this point",
+				"step [6] `assert 3 large nodes` (n=2): asserts 3 large nodes, but at most 2 can exist at this point",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		scenario, err := ScenarioFromBytes([]byte(c.scenario))
+		if err != nil {
+			t.Fatalf("(case: %s) failed to parse scenario: %s", c.desc, err.Error())
+		}
+		scenario.WorkingDir = dir
+		problems := []string{}
+		for _, problem := range Validate(scenario, c.nodeConfig, c.podConfig) {
+			problems = append(problems, problem.Error())
+		}
+		if !reflect.DeepEqual(c.expected, problems) {
+			t.Fatalf("(case: %s) expected problems:\n%q\ngot:\n%q", c.desc, c.expected, problems)
+		}
+	}
+}
+
+func Test_Validate_examples(t *testing.T) {
+	dir := filepath.Join("..", "..", "examples", "simple")
+	nodeConfig, err := NodeConfigFromFile(filepath.Join(dir, "nodes.yml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	podConfig, err := PodConfigFromFile(filepath.Join(dir, "pods.yml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "scenario*.yml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, path := range paths {
+		log.WithFields(log.Fields{"description": path}).Infof("Running test")
+		scenario, err := ScenarioFromFile(path)
+		if err != nil {
+			t.Fatalf("(case: %s) failed to read scenario: %s", path, err.Error())
+		}
+		if problems := Validate(scenario, nodeConfig, podConfig); len(problems) > 0 {
+			t.Fatalf("(case: %s) expected no problems, got: %v", path, problems)
+		}
+	}
+}
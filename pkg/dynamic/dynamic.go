@@ -28,6 +28,12 @@ func NewDynamicClient(dynamicClient dynamic.Interface, k8sClient kubernetes.Inte
 }
 
 func (d *DynamicClient) GetResourceFromObject(gvk schema.GroupVersionKind) (dynamic.ResourceInterface, error) {
+	resource, _, err := d.getResource(gvk)
+	return resource, err
+}
+
+// Returns the resource of the kind, and whether it is namespaced.
+func (d *DynamicClient) getResource(gvk schema.GroupVersionKind) (dynamic.ResourceInterface, bool, error) {
 
 	gk := schema.GroupKind{
 		Group: gvk.Group,
@@ -37,23 +43,23 @@ func (d *DynamicClient) GetResourceFromObject(gvk schema.GroupVersionKind) (dyna
 	// Get the available resources from the client
 	groupResources, err := restmapper.GetAPIGroupResources(d.k8sclient.Discovery())
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// retrieve the required rest resource from the available mappings
 	restMapper := restmapper.NewDiscoveryRESTMapper(groupResources)
 	restMapping, err := restMapper.RESTMapping(gk, gvk.Version)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// create a resource of that and return it
 	resource := d.client.Resource(restMapping.Resource)
 	if restMapping.Scope.Name() == apimeta.RESTScopeNameNamespace {
 		// if namespaced, return the namespaced client
-		return resource.Namespace(d.namespace), nil
+		return resource.Namespace(d.namespace), true, nil
 	}
-	return resource, nil
+	return resource, false, nil
 }
 
 func (d *DynamicClient) getUnstructuredObjectFromFile(yamlPath string) (*unstructured.Unstructured, error) {
@@ -74,23 +80,60 @@ func (d *DynamicClient) getUnstructuredObjectFromFile(yamlPath string) (*unstruc
 	return object, nil
 }
 
-func (d *DynamicClient) Create(yamlPath string) error {
+// Namespaced reports whether the object in the yaml file is of a namespaced
+// kind.
+func (d *DynamicClient) Namespaced(yamlPath string) (bool, error) {
+	object, err := d.getUnstructuredObjectFromFile(yamlPath)
+	if err != nil {
+		return false, err
+	}
+	_, namespaced, err := d.getResource(object.GroupVersionKind())
+	return namespaced, err
+}
+
+// Create creates the object in the yaml file with the labels added to it and
+// to its pod template, if it has one, and returns the name of the object.
+// Namespaced objects get a generated name starting with the name in the
+// file, so that several instances, or instances left behind by an earlier
+// run, do not collide.
+func (d *DynamicClient) Create(yamlPath string, labels map[string]string) (string, error) {
 
 	object, err := d.getUnstructuredObjectFromFile(yamlPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	// Get the group, version and kind of the new object
 	gvk := object.GroupVersionKind()
-	resourceInterface, err := d.GetResourceFromObject(gvk)
+	resourceInterface, namespaced, err := d.getResource(gvk)
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = resourceInterface.Create(object, metav1.CreateOptions{})
-	return err
+
+	object.SetLabels(merge(object.GetLabels(), labels))
+	templateLabels, found, err := unstructured.NestedStringMap(object.Object, "spec", "template", "metadata", "labels")
+	if err != nil {
+		return "", err
+	}
+	if found {
+		if err := unstructured.SetNestedStringMap(object.Object, merge(templateLabels, labels), "spec", "template", "metadata", "labels"); err != nil {
+			return "", err
+		}
+	}
+	if namespaced && object.GetName() != "" {
+		object.SetGenerateName(object.GetName() + "-")
+		object.SetName("")
+	}
+
+	created, err := resourceInterface.Create(object, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return created.GetName(), nil
 }
 
-func (d *DynamicClient) Delete(yamlPath string) error {
+// Delete deletes the object in the yaml file, or the instance of it with the
+// given name if the name is not empty.
+func (d *DynamicClient) Delete(yamlPath string, name string) error {
 	object, err := d.getUnstructuredObjectFromFile(yamlPath)
 	if err != nil {
 		return err
@@ -101,9 +144,23 @@ func (d *DynamicClient) Delete(yamlPath string) error {
 	if err != nil {
 		return err
 	}
+	if name == "" {
+		name = object.GetName()
+	}
 	propagationPolicy := metav1.DeletePropagationForeground
 	deleteOptions := &metav1.DeleteOptions{
 		PropagationPolicy: &propagationPolicy,
 	}
-	return resourceInterface.Delete(object.GetName(), deleteOptions)
+	return resourceInterface.Delete(name, deleteOptions)
+}
+
+func merge(labels map[string]string, extra map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range labels {
+		result[k] = v
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+	return result
 }
@@ -0,0 +1,281 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Most recent events kept for each pod in the diagnostics of a failed assert.
+const diagnosticEventsPerPod = 5
+
+// Most pods named in the error message of a failed assert.
+const summaryPods = 3
+
+// PodDiagnostics describes a pod that matched, or nearly matched, a failed
+// assert. Near-matching pods have the asserted class but a different phase,
+// node or scheduling status.
+type PodDiagnostics struct {
+	Name       string          `json:"name"`
+	Class      string          `json:"class"`
+	Node       string          `json:"node"`
+	Phase      corev1.PodPhase `json:"phase"`
+	Matched    bool            `json:"matched"`
+	Conditions []string        `json:"conditions"`
+	Events     []string        `json:"events"`
+}
+
+// Why the pod is where it is: the reason it could not be scheduled, or else
+// its most recent event.
+func (p PodDiagnostics) reason() string {
+	for _, c := range p.Conditions {
+		if strings.HasPrefix(c, string(corev1.PodScheduled)+"=False") {
+			return c
+		}
+	}
+	if len(p.Events) > 0 {
+		return p.Events[len(p.Events)-1]
+	}
+	return ""
+}
+
+func (p PodDiagnostics) String() string {
+	s := fmt.Sprintf("%s (%s", p.Name, p.Phase)
+	if p.Node != "" {
+		s += " on " + p.Node
+	}
+	if reason := p.reason(); reason != "" {
+		s += ": " + reason
+	}
+	return s + ")"
+}
+
+// AssertDiagnostics is the state of the cluster when an assert failed.
+type AssertDiagnostics struct {
+	Time   time.Time         `json:"time"`
+	Assert string            `json:"assert"`
+	Pods   []PodDiagnostics  `json:"pods"`
+	Nodes  []NodeUtilization `json:"nodes"`
+	// Where the diagnostics were written, if anywhere
+	Dir string `json:"-"`
+}
+
+func (d *AssertDiagnostics) nearMatches() []PodDiagnostics {
+	near := []PodDiagnostics{}
+	for _, p := range d.Pods {
+		if !p.Matched {
+			near = append(near, p)
+		}
+	}
+	return near
+}
+
+// Summary is appended to the error of the failed assert: the near-matching
+// pods, how full the nodes are, and where to find the rest.
+func (d *AssertDiagnostics) Summary() string {
+	parts := []string{}
+	if near := d.nearMatches(); len(near) > 0 {
+		described := []string{}
+		for i, p := range near {
+			if i == summaryPods {
+				described = append(described, fmt.Sprintf("and %d more", len(near)-summaryPods))
+				break
+			}
+			described = append(described, p.String())
+		}
+		parts = append(parts, fmt.Sprintf("%d near-matching pods: %s", len(near), strings.Join(described, ", ")))
+	}
+
+	sample := UtilizationSample{Nodes: d.Nodes}
+	cluster := sample.Cluster()
+	parts = append(parts, fmt.Sprintf("%d nodes with %s of %s cpu and %s of %s memory allocated",
+		cluster.Nodes,
+		cpuString(cluster.Allocated["cpu"]), cpuString(cluster.Allocatable["cpu"]),
+		memoryString(cluster.Allocated["memory"]), memoryString(cluster.Allocatable["memory"])))
+
+	if d.Dir != "" {
+		parts = append(parts, "diagnostics in "+d.Dir)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Report is the human readable form of the diagnostics.
+func (d *AssertDiagnostics) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "assert: %s\ntime: %s\n", d.Assert, d.Time.Format(time.RFC3339Nano))
+
+	for _, matched := range []bool{true, false} {
+		heading := "matching pods"
+		if !matched {
+			heading = "near-matching pods"
+		}
+		fmt.Fprintf(&b, "\n%s:\n", heading)
+		found := false
+		for _, p := range d.Pods {
+			if p.Matched != matched {
+				continue
+			}
+			found = true
+			node := p.Node
+			if node == "" {
+				node = "<none>"
+			}
+			fmt.Fprintf(&b, "  %s class=%s phase=%s node=%s\n", p.Name, p.Class, p.Phase, node)
+			for _, c := range p.Conditions {
+				fmt.Fprintf(&b, "    condition: %s\n", c)
+			}
+			for _, e := range p.Events {
+				fmt.Fprintf(&b, "    event: %s\n", e)
+			}
+		}
+		if !found {
+			fmt.Fprintf(&b, "  none\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\nnodes:\n")
+	if len(d.Nodes) == 0 {
+		fmt.Fprintf(&b, "  none\n")
+	}
+	for _, n := range d.Nodes {
+		resources := []string{}
+		for _, name := range resourceNames(n.Allocatable) {
+			resources = append(resources, fmt.Sprintf("%s=%d/%d", name, n.Allocated[name], n.Allocatable[name]))
+		}
+		fmt.Fprintf(&b, "  %s class=%s %s\n", n.Name, n.Class, strings.Join(resources, " "))
+	}
+	return b.String()
+}
+
+// Writes the diagnostics to diagnostics.json and diagnostics.txt in the
+// directory, creating it if needed.
+func (d *AssertDiagnostics) write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "diagnostics.json"), data, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "diagnostics.txt"), []byte(d.Report()), 0644); err != nil {
+		return err
+	}
+	d.Dir = dir
+	return nil
+}
+
+// Collects the diagnostics of a failed assert from the informer cache.
+// Matched pods are the ones the assert counted, candidates all the pods of
+// the asserted class.
+func (r *runner) collectDiagnostics(assert string, matched, candidates []*corev1.Pod) (*AssertDiagnostics, error) {
+	events, err := r.cache.eventLister.Events(r.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := r.cache.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	pods, err := r.cache.boundPodLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &AssertDiagnostics{
+		Time:   now,
+		Assert: assert,
+		Pods:   podDiagnostics(matched, candidates, events),
+		Nodes:  utilizationOf(now, nodes, pods).Nodes,
+	}, nil
+}
+
+func podDiagnostics(matched, candidates []*corev1.Pod, events []*corev1.Event) []PodDiagnostics {
+	isMatched := map[types.UID]bool{}
+	for _, pod := range matched {
+		isMatched[pod.UID] = true
+	}
+	podEvents := map[types.UID][]*corev1.Event{}
+	for _, ev := range events {
+		if ev.InvolvedObject.Kind == "Pod" {
+			podEvents[ev.InvolvedObject.UID] = append(podEvents[ev.InvolvedObject.UID], ev)
+		}
+	}
+
+	result := []PodDiagnostics{}
+	for _, pod := range candidates {
+		d := PodDiagnostics{
+			Name:       pod.Name,
+			Class:      pod.Labels["np.class"],
+			Node:       pod.Spec.NodeName,
+			Phase:      pod.Status.Phase,
+			Matched:    isMatched[pod.UID],
+			Conditions: []string{},
+			Events:     []string{},
+		}
+		for _, c := range pod.Status.Conditions {
+			condition := fmt.Sprintf("%s=%s", c.Type, c.Status)
+			if c.Reason != "" || c.Message != "" {
+				condition += fmt.Sprintf(" (%s: %s)", c.Reason, c.Message)
+			}
+			d.Conditions = append(d.Conditions, condition)
+		}
+		evs := podEvents[pod.UID]
+		sort.SliceStable(evs, func(i, j int) bool { return evs[i].LastTimestamp.Before(&evs[j].LastTimestamp) })
+		if len(evs) > diagnosticEventsPerPod {
+			evs = evs[len(evs)-diagnosticEventsPerPod:]
+		}
+		for _, ev := range evs {
+			event := fmt.Sprintf("%s %s: %s", ev.Type, ev.Reason, ev.Message)
+			if ev.Count > 1 {
+				event += fmt.Sprintf(" (x%d)", ev.Count)
+			}
+			d.Events = append(d.Events, event)
+		}
+		result = append(result, d)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Matched != result[j].Matched {
+			return result[i].Matched
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Names the directory holding the artifacts of a step, e.g. "setup-step-2".
+func stepDir(step *StepResult) string {
+	if step.Section != "" {
+		return fmt.Sprintf("%s-step-%d", step.Section, step.Index+1)
+	}
+	return fmt.Sprintf("step-%d", step.Index+1)
+}
+
+func resourceNames(resources ResourceList) []string {
+	names := []string{}
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func cpuString(millis int64) string {
+	return resource.NewMilliQuantity(millis, resource.DecimalSI).String()
+}
+
+func memoryString(bytes int64) string {
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
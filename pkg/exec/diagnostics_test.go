@@ -0,0 +1,118 @@
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_AssertDiagnostics(t *testing.T) {
+	start := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	pod := func(name, node string, phase corev1.PodPhase, conditions ...corev1.PodCondition) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name), Labels: map[string]string{"np.class": "1-cpu"}},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: phase, Conditions: conditions},
+		}
+	}
+	event := func(pod string, seconds int, reason, message string) *corev1.Event {
+		return &corev1.Event{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", UID: types.UID(pod)},
+			Type:           "Normal",
+			Reason:         reason,
+			Message:        message,
+			LastTimestamp:  metav1.NewTime(start.Add(time.Duration(seconds) * time.Second)),
+		}
+	}
+	unschedulable := corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionFalse,
+		Reason: corev1.PodReasonUnschedulable, Message: "0/2 nodes are available: 2 Insufficient cpu."}
+
+	running := pod("a", "node-1", corev1.PodRunning)
+	pending := pod("b", "", corev1.PodPending, unschedulable)
+	succeeded := pod("c", "node-2", corev1.PodSucceeded)
+	nodes := []NodeUtilization{
+		{Name: "node-1", Allocated: ResourceList{"cpu": 2000, "memory": 1 << 30}, Allocatable: ResourceList{"cpu": 2000, "memory": 4 << 30}},
+		{Name: "node-2", Allocated: ResourceList{"cpu": 500, "memory": 0}, Allocatable: ResourceList{"cpu": 2000, "memory": 4 << 30}},
+	}
+
+	cases := []struct {
+		desc       string
+		matched    []*corev1.Pod
+		candidates []*corev1.Pod
+		events     []*corev1.Event
+		expected   string
+	}{
+		{
+			desc:     "no pods",
+			expected: "2 nodes with 2500m of 4 cpu and 1Gi of 8Gi memory allocated",
+		},
+		{
+			desc:       "near-matching pods, with their scheduling condition or latest event",
+			matched:    []*corev1.Pod{running},
+			candidates: []*corev1.Pod{succeeded, pending, running},
+			events: []*corev1.Event{
+				event("c", 2, "Killing", "Stopping container"),
+				event("c", 1, "Started", "Started container"),
+				event("a", 1, "Started", "Started container"),
+			},
+			expected: "2 near-matching pods: " +
+				"b (Pending: PodScheduled=False (Unschedulable: 0/2 nodes are available: 2 Insufficient cpu.)), " +
+				"c (Succeeded on node-2: Normal Killing: Stopping container); " +
+				"2 nodes with 2500m of 4 cpu and 1Gi of 8Gi memory allocated",
+		},
+		{
+			desc:       "summary names at most three pods",
+			candidates: []*corev1.Pod{pod("a", "", corev1.PodPending), pod("b", "", corev1.PodPending), pod("c", "", corev1.PodPending), pod("d", "", corev1.PodPending)},
+			expected:   "4 near-matching pods: a (Pending), b (Pending), c (Pending), and 1 more; 2 nodes with 2500m of 4 cpu and 1Gi of 8Gi memory allocated",
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		d := &AssertDiagnostics{Time: start, Pods: podDiagnostics(c.matched, c.candidates, c.events), Nodes: nodes}
+		if summary := d.Summary(); summary != c.expected {
+			t.Fatalf("(case: %s) expected summary:\n%s\ngot:\n%s", c.desc, c.expected, summary)
+		}
+	}
+}
+
+func Test_AssertDiagnostics_write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	d := &AssertDiagnostics{
+		Assert: "assert 2 1-cpu pods is running",
+		Pods:   []PodDiagnostics{{Name: "a", Class: "1-cpu", Phase: corev1.PodPending, Conditions: []string{}, Events: []string{"Warning FailedScheduling: no nodes"}}},
+		Nodes:  []NodeUtilization{{Name: "node-1", Allocated: ResourceList{"cpu": 0}, Allocatable: ResourceList{"cpu": 1000}}},
+	}
+	artifacts := filepath.Join(dir, stepDir(&StepResult{Section: "setup", Index: 1}))
+	if err := d.write(artifacts); err != nil {
+		t.Fatalf("failed to write diagnostics: %s", err.Error())
+	}
+	if !strings.HasSuffix(d.Summary(), "diagnostics in "+filepath.Join(dir, "setup-step-2")) {
+		t.Fatalf("expected the summary to name the directory, got: %s", d.Summary())
+	}
+	report, err := ioutil.ReadFile(filepath.Join(artifacts, "diagnostics.txt"))
+	if err != nil {
+		t.Fatalf("failed to read report: %s", err.Error())
+	}
+	for _, expected := range []string{"near-matching pods:\n  a class=1-cpu phase=Pending node=<none>", "event: Warning FailedScheduling: no nodes", "node-1 class= cpu=0/1000"} {
+		if !strings.Contains(string(report), expected) {
+			t.Fatalf("expected the report to contain %q, got:\n%s", expected, report)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(artifacts, "diagnostics.json")); err != nil {
+		t.Fatalf("expected diagnostics.json: %s", err.Error())
+	}
+}
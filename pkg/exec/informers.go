@@ -0,0 +1,183 @@
+package exec
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/IntelAI/nodus/pkg/node"
+)
+
+// Upper bound on how long a write may take to show up in the informer cache.
+const cacheWaitTimeout = 30 * time.Second
+
+// Local cache of the pods, nodes and events the runner asserts against,
+// kept up to date by shared informers.
+type clusterCache struct {
+	factory   informers.SharedInformerFactory
+	podLister corelisters.PodLister
+	// Pods bound to any node in any namespace, since pods of other
+	// namespaces take up the resources of the same nodes. They come from
+	// the pod informer of the node manager, which watches them anyway.
+	nodes          node.NodeManager
+	boundPodLister corelisters.PodLister
+	nodeLister     corelisters.NodeLister
+	eventLister    corelisters.EventLister
+	pods           *podTracker
+	// Receives a signal whenever a watch event arrives. Signals coalesce,
+	// so readers must re-evaluate the whole cache on every receive.
+	changed   chan struct{}
+	stop      chan struct{}
+	startOnce sync.Once
+	startErr  error
+	stopOnce  sync.Once
+}
+
+func newClusterCache(client *kubernetes.Clientset, namespace string, manager node.NodeManager) *clusterCache {
+	// Nodes are cluster scoped and ignore the namespace option.
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(namespace))
+	c := &clusterCache{
+		factory:        factory,
+		nodes:          manager,
+		boundPodLister: manager.BoundPods(),
+		changed:        make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+		pods:           newPodTracker(),
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) { c.notify() },
+		DeleteFunc: func(obj interface{}) { c.notify() },
+	}
+
+	pods := factory.Core().V1().Pods()
+	pods.Informer().AddEventHandler(handler)
+	pods.Informer().AddEventHandler(c.pods.handler())
+	c.podLister = pods.Lister()
+
+	nodes := factory.Core().V1().Nodes()
+	nodes.Informer().AddEventHandler(handler)
+	c.nodeLister = nodes.Lister()
+
+	events := factory.Core().V1().Events()
+	events.Informer().AddEventHandler(handler)
+	c.eventLister = events.Lister()
+
+	return c
+}
+
+// Starts all informers and blocks until their caches are populated.
+// Only the first call has any effect.
+func (c *clusterCache) start() error {
+	c.startOnce.Do(func() {
+		c.factory.Start(c.stop)
+		for informerType, synced := range c.factory.WaitForCacheSync(c.stop) {
+			if !synced {
+				c.startErr = fmt.Errorf("failed to sync informer cache for %s", informerType)
+				return
+			}
+		}
+		if err := c.nodes.Start(); err != nil {
+			c.startErr = err
+			return
+		}
+		c.pods.markSynced()
+		log.Debug("informer caches synced")
+	})
+	return c.startErr
+}
+
+func (c *clusterCache) shutdown() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+func (c *clusterCache) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Blocks until the cache satisfies the condition, so that asserts following
+// a write through the API observe that write.
+func (c *clusterCache) waitFor(synced func() bool) error {
+	timeout := time.After(cacheWaitTimeout)
+	for !synced() {
+		select {
+		case <-c.changed:
+		case <-timeout:
+			return fmt.Errorf("timed out after %s waiting for the informer cache to observe changes", cacheWaitTimeout)
+		}
+	}
+	return nil
+}
+
+// Conditions for waitFor that hold once the cache has observed a write.
+
+func (c *clusterCache) nodesCreated(names []string) func() bool {
+	return func() bool {
+		for _, name := range names {
+			if _, err := c.nodeLister.Get(name); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (c *clusterCache) nodesDeleted(names []string) func() bool {
+	return func() bool {
+		for _, name := range names {
+			if _, err := c.nodeLister.Get(name); err == nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (c *clusterCache) podsCreated(namespace string, names []string) func() bool {
+	return func() bool {
+		for _, name := range names {
+			if _, err := c.podLister.Pods(namespace).Get(name); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Deleted pods may linger until their node finalizes them, so terminating
+// pods count as deleted.
+func (c *clusterCache) podsDeleted(namespace string, names []string) func() bool {
+	return func() bool {
+		for _, name := range names {
+			pod, err := c.podLister.Pods(namespace).Get(name)
+			if err == nil && pod.DeletionTimestamp == nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Holds once every pod's cached resource version differs from the version
+// it had before the update.
+func (c *clusterCache) podsUpdated(namespace string, previousVersions map[string]string) func() bool {
+	return func() bool {
+		for name, version := range previousVersions {
+			pod, err := c.podLister.Pods(namespace).Get(name)
+			if err == nil && pod.ResourceVersion == version {
+				return false
+			}
+		}
+		return true
+	}
+}
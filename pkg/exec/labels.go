@@ -0,0 +1,46 @@
+package exec
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// OwnerLabel marks the namespaces nptest creates, so that they can be told
+// apart from the namespaces it only uses.
+const (
+	OwnerLabel = "np.owner"
+	OwnerValue = "nodus"
+)
+
+// RunLabel holds the ID of the run that created a node, pod, namespace or
+// object from yaml, so that a run only acts on its own objects and not on
+// those left behind by an earlier one.
+const RunLabel = "np.run"
+
+// Returns a random (version 4) UUID.
+func newRunID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate a run ID: %s", err.Error()))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Whether an object with these labels belongs to the run: it was created by
+// the run, or not by nptest at all, e.g. the nodes of npsim.
+func inRun(labels map[string]string, runID string) bool {
+	run, ok := labels[RunLabel]
+	return !ok || run == runID
+}
+
+// Returns a copy of the labels with the run label added.
+func withRun(labels map[string]string, runID string) map[string]string {
+	result := map[string]string{}
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[RunLabel] = runID
+	return result
+}
@@ -0,0 +1,52 @@
+package exec
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_newRunID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := newRunID(), newRunID()
+	if !uuid.MatchString(first) {
+		t.Fatalf("expected a version 4 uuid, got %s", first)
+	}
+	if first == second {
+		t.Fatalf("expected different run IDs, got %s twice", first)
+	}
+}
+
+func Test_inRun(t *testing.T) {
+	cases := []struct {
+		desc     string
+		labels   map[string]string
+		expected bool
+	}{
+		{desc: "created by the run", labels: map[string]string{RunLabel: "this", "np.class": "large"}, expected: true},
+		{desc: "created by another run", labels: map[string]string{RunLabel: "other", "np.class": "large"}, expected: false},
+		{desc: "not created by nptest", labels: map[string]string{"np.class": "large"}, expected: true},
+		{desc: "no labels", expected: true},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		if actual := inRun(c.labels, "this"); actual != c.expected {
+			t.Fatalf("(case: %s) expected %t, got %t", c.desc, c.expected, actual)
+		}
+	}
+}
+
+func Test_withRun(t *testing.T) {
+	labels := map[string]string{"np.class": "large"}
+	actual := withRun(labels, "this")
+	expected := map[string]string{"np.class": "large", RunLabel: "this"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+	if _, ok := labels[RunLabel]; ok {
+		t.Fatalf("expected the labels of the class to be left alone, got %v", labels)
+	}
+}
@@ -0,0 +1,53 @@
+package exec
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logrus hook that records log lines in the result of the step that is
+// running, so that reports can show them next to the step.
+type logCapture struct {
+	mu   sync.Mutex
+	step *StepResult
+}
+
+// Adds the hook to the standard logger and returns a function that removes
+// it again.
+func captureLogs() (*logCapture, func()) {
+	capture := &logCapture{}
+	logger := log.StandardLogger()
+	previous := log.LevelHooks{}
+	for level, hooks := range logger.Hooks {
+		previous[level] = append([]log.Hook{}, hooks...)
+	}
+	logger.AddHook(capture)
+	return capture, func() { logger.ReplaceHooks(previous) }
+}
+
+func (c *logCapture) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (c *logCapture) Fire(entry *log.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.step == nil {
+		return nil
+	}
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+	c.step.Logs = append(c.step.Logs, strings.TrimSuffix(line, "\n"))
+	return nil
+}
+
+// Sends log lines to the given step until the next call; nil stops capturing.
+func (c *logCapture) setStep(step *StepResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.step = step
+}
@@ -0,0 +1,149 @@
+package exec
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// LatencyStats summarizes a set of durations, in seconds.
+type LatencyStats struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50Seconds"`
+	P90   float64 `json:"p90Seconds"`
+	P99   float64 `json:"p99Seconds"`
+	Max   float64 `json:"maxSeconds"`
+}
+
+func (l LatencyStats) String() string {
+	if l.Count == 0 {
+		return "no pods"
+	}
+	return fmt.Sprintf("p50 %s, p90 %s, p99 %s, max %s (%d pods)",
+		seconds(l.P50), seconds(l.P90), seconds(l.P99), seconds(l.Max), l.Count)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
+
+// ClassMetrics are the latencies of the pods of one class.
+type ClassMetrics struct {
+	SchedulingLatency LatencyStats `json:"schedulingLatency"`
+	TimeToRunning     LatencyStats `json:"timeToRunning"`
+}
+
+// SchedulingMetrics measure the scheduler during a run, from the pod
+// timelines: scheduling latency is creation to binding, time to running is
+// creation to the Running phase. Pods that existed before the run are left
+// out.
+type SchedulingMetrics struct {
+	PodsCreated       int          `json:"podsCreated"`
+	PodsScheduled     int          `json:"podsScheduled"`
+	PodsRunning       int          `json:"podsRunning"`
+	SchedulingLatency LatencyStats `json:"schedulingLatency"`
+	TimeToRunning     LatencyStats `json:"timeToRunning"`
+	// Pods bound per second, from the first pod creation to the last binding
+	Throughput float64 `json:"bindingThroughputPodsPerSecond"`
+	// Most pods bound within one second
+	PeakThroughput float64                  `json:"peakBindingThroughputPodsPerSecond"`
+	Classes        map[string]*ClassMetrics `json:"classes"`
+}
+
+func ComputeSchedulingMetrics(pods []*PodTimeline) *SchedulingMetrics {
+	m := &SchedulingMetrics{Classes: map[string]*ClassMetrics{}}
+	scheduling, running := []time.Duration{}, []time.Duration{}
+	classScheduling, classRunning := map[string][]time.Duration{}, map[string][]time.Duration{}
+	var first, last time.Time
+	binds := []time.Time{}
+
+	for _, pod := range pods {
+		if pod.PreExisting {
+			continue
+		}
+		m.PodsCreated++
+		if first.IsZero() || pod.Created.Before(first) {
+			first = pod.Created
+		}
+		if _, ok := classScheduling[pod.Class]; !ok {
+			classScheduling[pod.Class] = []time.Duration{}
+			classRunning[pod.Class] = []time.Duration{}
+		}
+		if !pod.Scheduled.IsZero() {
+			latency := pod.Scheduled.Sub(pod.Created)
+			scheduling = append(scheduling, latency)
+			classScheduling[pod.Class] = append(classScheduling[pod.Class], latency)
+			binds = append(binds, pod.Scheduled)
+			if pod.Scheduled.After(last) {
+				last = pod.Scheduled
+			}
+		}
+		if !pod.Running.IsZero() {
+			latency := pod.Running.Sub(pod.Created)
+			running = append(running, latency)
+			classRunning[pod.Class] = append(classRunning[pod.Class], latency)
+		}
+	}
+
+	m.PodsScheduled = len(scheduling)
+	m.PodsRunning = len(running)
+	m.SchedulingLatency = latencyStats(scheduling)
+	m.TimeToRunning = latencyStats(running)
+	for class := range classScheduling {
+		m.Classes[class] = &ClassMetrics{
+			SchedulingLatency: latencyStats(classScheduling[class]),
+			TimeToRunning:     latencyStats(classRunning[class]),
+		}
+	}
+
+	if len(binds) > 0 {
+		window := last.Sub(first).Seconds()
+		if window < 1 {
+			window = 1
+		}
+		m.Throughput = float64(len(binds)) / window
+		m.PeakThroughput = float64(peakPerSecond(binds))
+	}
+	return m
+}
+
+// Returns the most times that fall within any one second.
+func peakPerSecond(times []time.Time) int {
+	sorted := append([]time.Time{}, times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	peak, from := 0, 0
+	for to := range sorted {
+		for sorted[to].Sub(sorted[from]) >= time.Second {
+			from++
+		}
+		if to-from+1 > peak {
+			peak = to - from + 1
+		}
+	}
+	return peak
+}
+
+func latencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		Count: len(sorted),
+		P50:   percentile(sorted, 50).Seconds(),
+		P90:   percentile(sorted, 90).Seconds(),
+		P99:   percentile(sorted, 99).Seconds(),
+		Max:   sorted[len(sorted)-1].Seconds(),
+	}
+}
+
+// Nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
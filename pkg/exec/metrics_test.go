@@ -0,0 +1,46 @@
+package exec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ComputeSchedulingMetrics(t *testing.T) {
+	start := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	pods := []*PodTimeline{
+		// Existed before the run
+		{Name: "old", Class: "1-cpu", Created: at(0), Scheduled: at(0), Running: at(0), PreExisting: true},
+		{Name: "a", Class: "1-cpu", Created: at(0), Scheduled: at(100), Running: at(500)},
+		{Name: "b", Class: "1-cpu", Created: at(0), Scheduled: at(200), Running: at(900)},
+		{Name: "c", Class: "4-cpu", Created: at(1000), Scheduled: at(1300)},
+		{Name: "d", Class: "4-cpu", Created: at(1000), Scheduled: at(3900)},
+		// Never scheduled
+		{Name: "e", Class: "4-cpu", Created: at(2000)},
+	}
+	expected := &SchedulingMetrics{
+		PodsCreated:       5,
+		PodsScheduled:     4,
+		PodsRunning:       2,
+		SchedulingLatency: LatencyStats{Count: 4, P50: 0.2, P90: 2.9, P99: 2.9, Max: 2.9},
+		TimeToRunning:     LatencyStats{Count: 2, P50: 0.5, P90: 0.9, P99: 0.9, Max: 0.9},
+		Throughput:        4 / (3900 * time.Millisecond).Seconds(),
+		PeakThroughput:    2,
+		Classes: map[string]*ClassMetrics{
+			"1-cpu": {
+				SchedulingLatency: LatencyStats{Count: 2, P50: 0.1, P90: 0.2, P99: 0.2, Max: 0.2},
+				TimeToRunning:     LatencyStats{Count: 2, P50: 0.5, P90: 0.9, P99: 0.9, Max: 0.9},
+			},
+			"4-cpu": {
+				SchedulingLatency: LatencyStats{Count: 2, P50: 0.3, P90: 2.9, P99: 2.9, Max: 2.9},
+			},
+		},
+	}
+
+	actual := ComputeSchedulingMetrics(pods)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected metrics: %+v, but got %+v", expected, actual)
+	}
+}
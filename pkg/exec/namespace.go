@@ -0,0 +1,68 @@
+package exec
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// FreshNamespace returns a namespace name that is unique to this run, so
+// that parallel runs cannot collide.
+func FreshNamespace() string {
+	return "nptest-" + utilrand.String(8)
+}
+
+// Creates the runner's namespace, labeled as owned by nodus, unless it
+// already exists. Only the first call has any effect.
+func (r *runner) ensureNamespace() error {
+	r.namespaceOnce.Do(func() {
+		namespaces := r.client.CoreV1().Namespaces()
+		existing, err := namespaces.Get(r.namespace, metav1.GetOptions{})
+		if err == nil {
+			if existing.Status.Phase == corev1.NamespaceTerminating {
+				r.namespaceErr = fmt.Errorf("namespace %s is being deleted", r.namespace)
+			}
+			return
+		}
+		if !apierrors.IsNotFound(err) {
+			r.namespaceErr = fmt.Errorf("failed to get namespace %s: %s", r.namespace, err.Error())
+			return
+		}
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   r.namespace,
+				Labels: map[string]string{OwnerLabel: OwnerValue, RunLabel: r.runID},
+			},
+		}
+		if _, err := namespaces.Create(ns); err != nil {
+			// Another run may have created it in the meantime
+			if !apierrors.IsAlreadyExists(err) {
+				r.namespaceErr = fmt.Errorf("failed to create namespace %s: %s", r.namespace, err.Error())
+			}
+			return
+		}
+		r.createdNamespace = true
+		log.WithFields(log.Fields{"namespace": r.namespace}).Info("created namespace")
+	})
+	return r.namespaceErr
+}
+
+// Deletes the runner's namespace if the runner created it and was asked to.
+func (r *runner) deleteNamespace() {
+	if !r.deleteCreatedNamespace || !r.createdNamespace {
+		return
+	}
+	propagation := metav1.DeletePropagationBackground
+	err := r.client.CoreV1().Namespaces().Delete(r.namespace, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.WithFields(log.Fields{"namespace": r.namespace, "error": err.Error()}).Warn("failed to delete namespace")
+		return
+	}
+	r.createdNamespace = false
+	log.WithFields(log.Fields{"namespace": r.namespace}).Info("deleted namespace")
+}
@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/config"
+)
+
+type StepStatus string
+
+const (
+	StepPassed  StepStatus = "passed"
+	StepFailed  StepStatus = "failed"
+	StepSkipped StepStatus = "skipped"
+)
+
+// StepResult is the outcome of running, or skipping, one step of a scenario.
+type StepResult struct {
+	// "setup", "teardown", "onFailure" or empty for the main steps
+	Section     string
+	Index       int
+	Description string
+	Step        *config.Step
+	Status      StepStatus
+	Start       time.Time
+	Duration    time.Duration
+	Err         error
+	// Log lines written while the step ran
+	Logs []string
+	// Directory holding the diagnostics collected when the step failed
+	Artifacts string
+}
+
+// Name identifies the step in logs and reports, e.g. "setup step [2]".
+func (s *StepResult) Name() string {
+	if s.Section != "" {
+		return fmt.Sprintf("%s step [%d]", s.Section, s.Index+1)
+	}
+	return fmt.Sprintf("step [%d]", s.Index+1)
+}
+
+// ScenarioResult is the outcome of RunScenario, with the result of every
+// step in the order they ran.
+type ScenarioResult struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Steps    []*StepResult
+	// The failure that stopped setup or the main steps early, if any
+	Aborted error
+	// Every pod observed during the run, ordered by creation
+	Pods []*PodTimeline
+	// Node utilization over the run
+	Utilization []*UtilizationSample
+	Metrics     *SchedulingMetrics
+}
+
+func newScenarioResult(name string) *ScenarioResult {
+	return &ScenarioResult{Name: name, Start: time.Now()}
+}
+
+func (r *ScenarioResult) add(step *StepResult) {
+	r.Steps = append(r.Steps, step)
+}
+
+// Records the steps that did not run because the scenario was aborted.
+func (r *ScenarioResult) skip(section string, steps []*config.Step, from int) {
+	for i := from; i < len(steps); i++ {
+		r.add(&StepResult{
+			Section:     section,
+			Index:       i,
+			Description: describe(steps[i]),
+			Step:        steps[i],
+			Status:      StepSkipped,
+		})
+	}
+}
+
+// Failures returns the failed steps of the given sections, or of all
+// sections if none are given.
+func (r *ScenarioResult) Failures(sections ...string) []*StepResult {
+	failures := []*StepResult{}
+	for _, step := range r.Steps {
+		if step.Status != StepFailed {
+			continue
+		}
+		if len(sections) == 0 {
+			failures = append(failures, step)
+			continue
+		}
+		for _, section := range sections {
+			if step.Section == section {
+				failures = append(failures, step)
+				break
+			}
+		}
+	}
+	return failures
+}
+
+func (r *ScenarioResult) Failed() bool {
+	return len(r.Failures()) > 0
+}
+
+// Err summarizes the failures of the scenario. Failures of setup and the main
+// steps take precedence over teardown failures, so that cleanup problems do
+// not mask the failure that matters.
+func (r *ScenarioResult) Err() error {
+	failures := r.Failures("setup", "")
+	if len(failures) == 0 {
+		failures = r.Failures("teardown")
+		if len(failures) == 0 {
+			return nil
+		}
+		return fmt.Errorf("teardown failed: %s", joinFailures(failures))
+	}
+	if len(failures) == 1 {
+		return failures[0].Err
+	}
+	return fmt.Errorf("%d steps failed: %s", len(failures), joinFailures(failures))
+}
+
+func joinFailures(failures []*StepResult) string {
+	messages := []string{}
+	for _, f := range failures {
+		messages = append(messages, fmt.Sprintf("%s: %s", f.Name(), f.Err.Error()))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Describes a step in logs and reports, with its iteration if it was
+// expanded from a block.
+func describe(step *config.Step) string {
+	if step.Iteration != "" {
+		return fmt.Sprintf("%s (%s)", step.String(), step.Iteration)
+	}
+	return step.String()
+}
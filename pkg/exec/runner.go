@@ -3,9 +3,13 @@ package exec
 import (
 	"fmt"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
@@ -15,29 +19,46 @@ import (
 	"github.com/IntelAI/nodus/pkg/node"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	wait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/labels"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
 )
 
+// Interval at which API availability asserts are re-checked.
+const apiPollInterval = 1 * time.Second
+
 type ScenarioRunner interface {
-	RunScenario(scenario *config.Scenario) error
+	RunScenario(scenario *config.Scenario) (*ScenarioResult, error)
 	RunAssert(step *config.Step) error
 	RunCreate(step *config.Step) error
 	RunChange(step *config.Step) error
 	RunDelete(step *config.Step) error
 	RunStep(step *config.Step) error
+	// Sets how often RunScenario samples node utilization
+	SetUtilizationInterval(interval time.Duration)
+	// Sets where the diagnostics of failed asserts are written, one
+	// directory per step; empty disables writing them
+	SetArtifactsDir(dir string)
+	// Sets whether Shutdown deletes the namespace, if the runner created it
+	SetDeleteNamespace(delete bool)
+	// The ID in the RunLabel of every object the runner creates
+	RunID() string
 	Shutdown()
 }
 
 func NewScenarioRunner(client *kubernetes.Clientset, namespace string, nodeConfig *config.NodeConfig, podConfig *config.PodConfig, dynamicClient *dynamic.DynamicClient) ScenarioRunner {
+	nodes := node.NewNodeManager(client)
 	return &runner{
-		client:        client,
-		namespace:     namespace,
-		nodeConfig:    nodeConfig,
-		podConfig:     podConfig,
-		gcPods:        map[string]bool{},
-		gcNodes:       map[string]bool{},
-		dynamicClient: dynamicClient,
-		gcObjects:     map[string]bool{},
+		client:         client,
+		namespace:      namespace,
+		nodeConfig:     nodeConfig,
+		podConfig:      podConfig,
+		gcPods:         map[string]bool{},
+		dynamicClient:  dynamicClient,
+		gcObjects:      map[string][]string{},
+		cache:          newClusterCache(client, namespace, nodes),
+		nodes:          nodes,
+		sampleInterval: utilizationSampleInterval,
+		runID:          newRunID(),
 	}
 }
 
@@ -48,9 +69,39 @@ type runner struct {
 	podConfig     *config.PodConfig
 	nodeConfig    *config.NodeConfig
 	gcPods        map[string]bool
-	gcNodes       map[string]bool
-	gcObjects     map[string]bool
-	workingDir    string
+	// Names of the objects created from each yaml file, in creation order
+	gcObjects  map[string][]string
+	runID      string
+	workingDir string
+	cache      *clusterCache
+	// Simulates the fake nodes the scenario creates
+	nodes node.NodeManager
+	// Set while RunScenario runs
+	logs           *logCapture
+	current        *StepResult
+	sampleInterval time.Duration
+	artifactsDir   string
+	namespaceOnce  sync.Once
+	namespaceErr   error
+	// Whether the namespace was created by this runner
+	createdNamespace       bool
+	deleteCreatedNamespace bool
+}
+
+func (r *runner) SetUtilizationInterval(interval time.Duration) {
+	r.sampleInterval = interval
+}
+
+func (r *runner) SetArtifactsDir(dir string) {
+	r.artifactsDir = dir
+}
+
+func (r *runner) SetDeleteNamespace(delete bool) {
+	r.deleteCreatedNamespace = delete
+}
+
+func (r *runner) RunID() string {
+	return r.runID
 }
 
 func (r *runner) Shutdown() {
@@ -61,34 +112,136 @@ func (r *runner) Shutdown() {
 		podClient.Delete(pod, deleteOptions)
 	}
 
-	nodeClient := r.client.CoreV1().Nodes()
-	for node := range r.gcNodes {
-		nodeClient.Delete(node, deleteOptions)
-	}
+	r.nodes.Stop()
 
-	for yaml := range r.gcObjects {
-		r.dynamicClient.Delete(yaml)
+	for yaml, names := range r.gcObjects {
+		for _, name := range names {
+			r.dynamicClient.Delete(yaml, name)
+		}
 	}
+
+	r.cache.shutdown()
+	r.deleteNamespace()
 }
 
-func (r *runner) RunScenario(scenario *config.Scenario) error {
-	log.WithFields(log.Fields{"name": scenario.Name}).Info("run scenario")
-	numSteps := len(scenario.Steps)
+func (r *runner) RunScenario(scenario *config.Scenario) (*ScenarioResult, error) {
+	log.WithFields(log.Fields{"name": scenario.Name, "run": r.runID}).Info("run scenario")
 	defer r.Shutdown()
 	r.workingDir = scenario.WorkingDir
-	for i, step := range scenario.Steps {
-		raw := scenario.RawSteps[i]
+	result := newScenarioResult(scenario.Name)
+	capture, stopCapture := captureLogs()
+	defer stopCapture()
+	r.logs = capture
+
+	var sampler *utilizationSampler
+	if err := r.prepare(); err == nil {
+		sampler = newUtilizationSampler(r.cache, r.sampleInterval)
+		sampler.start()
+	}
+
+	mode := stopOnFailure
+	if scenario.ContinueOnFailure {
+		mode = continueAfterAsserts
+	}
+	if r.runSteps(result, "setup", scenario.Setup, mode) {
+		r.runSteps(result, "", scenario.Steps, mode)
+	} else {
+		result.skip("", scenario.Steps, 0)
+	}
+	if result.Failed() && len(scenario.OnFailure) > 0 {
+		r.runSteps(result, "onFailure", scenario.OnFailure, continueAlways)
+	}
+
+	// Teardown failures must not mask the failure of the scenario itself.
+	failed := result.Failed()
+	r.runSteps(result, "teardown", scenario.Teardown, continueAlways)
+	if failures := result.Failures("teardown"); failed && len(failures) > 0 {
+		log.WithFields(log.Fields{"error": joinFailures(failures)}).Error("teardown failed")
+	}
+
+	if sampler != nil {
+		result.Utilization = sampler.shutdown()
+	}
+	result.Pods = r.cache.pods.timelines()
+	result.Metrics = ComputeSchedulingMetrics(result.Pods)
+	result.Duration = time.Since(result.Start)
+	return result, result.Err()
+}
+
+type failureMode int
+
+const (
+	stopOnFailure failureMode = iota
+	// Record failed asserts and go on; other failed steps still abort
+	continueAfterAsserts
+	continueAlways
+)
+
+// Runs the steps of a section of the scenario and records their results.
+// Returns false if a failure aborted the section, in which case the
+// remaining steps are recorded as skipped.
+func (r *runner) runSteps(result *ScenarioResult, section string, steps []*config.Step, mode failureMode) bool {
+	name := "step"
+	if section != "" {
+		name = section + " step"
+	}
+	for i, step := range steps {
+		stepResult := &StepResult{
+			Section:     section,
+			Index:       i,
+			Description: describe(step),
+			Step:        step,
+			Start:       time.Now(),
+		}
+		if r.logs != nil {
+			r.logs.setStep(stepResult)
+		}
+		r.current = stepResult
 		log.WithFields(log.Fields{
-			"description": raw,
-		}).Infof("run step [%d / %d]", i+1, numSteps)
-		if err := r.RunStep(step); err != nil {
-			return err
+			"description": stepResult.Description,
+		}).Infof("run %s [%d / %d]", name, i+1, len(steps))
+
+		err := r.RunStep(step)
+		stepResult.Duration = time.Since(stepResult.Start)
+		if r.logs != nil {
+			r.logs.setStep(nil)
+		}
+		r.current = nil
+		stepResult.Status = StepPassed
+		result.add(stepResult)
+		if err == nil {
+			continue
 		}
+
+		stepResult.Status = StepFailed
+		stepResult.Err = err
+		if mode == continueAlways || (mode == continueAfterAsserts && step.Verb == config.Assert) {
+			log.WithFields(log.Fields{
+				"description": stepResult.Description,
+				"error":       err.Error(),
+			}).Errorf("%s failed, continuing", name)
+			continue
+		}
+		result.Aborted = err
+		result.skip(section, steps, i+1)
+		return false
 	}
-	return nil
+	return true
+}
+
+// Creates the namespace if needed and starts the informer cache.
+func (r *runner) prepare() error {
+	if err := r.ensureNamespace(); err != nil {
+		return err
+	}
+	return r.cache.start()
 }
 
 func (r *runner) RunStep(step *config.Step) error {
+	if err := r.prepare(); err != nil {
+		return err
+	}
+
 	var err error
 	switch step.Verb {
 	case config.Assert:
@@ -111,48 +264,192 @@ func (r *runner) assertNode(assert *config.AssertStep) error {
 
 	// Get all the nodes with the optional class.
 
-	var labelSelector string
+	selector := labels.Everything()
 	if assert.Class != "" {
-		labelSelector = fmt.Sprintf("np.class=%s", assert.Class)
+		selector = labels.SelectorFromSet(labels.Set{"np.class": string(assert.Class)})
 	}
-	nodeList, err := r.client.CoreV1().Nodes().List(metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
+	nodes, err := r.cache.nodeLister.List(selector)
 	if err != nil {
 		return err
 	}
-	if nodeList.Items == nil || uint64(len(nodeList.Items)) != assert.Count {
+	nodes = r.nodesInRun(nodes)
+	if uint64(len(nodes)) != assert.Count {
 		if assert.Class != "" {
-			return fmt.Errorf("found %d nodes of class %s, but %d expected", len(nodeList.Items), assert.Class, assert.Count)
+			return fmt.Errorf("found %d nodes of class %s, but %d expected", len(nodes), assert.Class, assert.Count)
 		}
-		return fmt.Errorf("found %d nodes but %d expected", len(nodeList.Items), assert.Count)
+		return fmt.Errorf("found %d nodes but %d expected", len(nodes), assert.Count)
 	}
 	return nil
 }
 
 func (r *runner) assertPod(assert *config.AssertStep) error {
-	// Supported grammar: "assert" <count> [<class>] <object> [<is> <phase>] [<within> <count> seconds]
-	var labelSelector string
+	// Supported grammar: "assert" <count> [<class>] <object> [<is> ( <phase> | unschedulable [with reason <reason>] )] [on <class> <object>] [<within> <count> seconds]
+	pods, _, err := r.podsMatching(assert)
+	if err != nil {
+		return err
+	}
+	if uint64(len(pods)) != assert.Count {
+		if assert.Unschedulable {
+			if assert.Reason != "" {
+				return fmt.Errorf("found %d unschedulable pods of class %s with reason \"%s\", but %d expected", len(pods), assert.Class, assert.Reason, assert.Count)
+			}
+			return fmt.Errorf("found %d unschedulable pods of class %s, but %d expected", len(pods), assert.Class, assert.Count)
+		}
+		if assert.NodeClass != "" {
+			return fmt.Errorf("found %d pods of class %s and phase: %s on nodes of class %s, but %d expected", len(pods), assert.Class, assert.PodPhase, assert.NodeClass, assert.Count)
+		}
+		return fmt.Errorf("found %d pods of class %s and phase: %s, but %d expected", len(pods), assert.Class, assert.PodPhase, assert.Count)
+	}
+
+	return nil
+}
+
+// Returns the pods the assert counts, and all the pods of the asserted class
+// they were picked from.
+func (r *runner) podsMatching(assert *config.AssertStep) ([]*corev1.Pod, []*corev1.Pod, error) {
+	selector := labels.Everything()
 	if assert.Class != "" {
-		labelSelector = fmt.Sprintf("np.class=%s", assert.Class)
+		selector = labels.SelectorFromSet(labels.Set{"np.class": string(assert.Class)})
 	}
-	var fieldSelector string
-	if assert.PodPhase != "" {
-		fieldSelector = fmt.Sprintf("status.phase=%s", assert.PodPhase)
+	phase := assert.PodPhase
+	if assert.Unschedulable {
+		phase = corev1.PodPending
 	}
 
-	podList, err := r.client.CoreV1().Pods(r.namespace).List(metav1.ListOptions{
-		LabelSelector: labelSelector,
-		FieldSelector: fieldSelector,
-	})
+	cached, err := r.cache.podLister.Pods(r.namespace).List(selector)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	cached = r.podsInRun(cached)
+
+	pods := []*corev1.Pod{}
+	for _, pod := range cached {
+		if phase == "" || pod.Status.Phase == phase {
+			pods = append(pods, pod)
+		}
 	}
-	if podList.Items == nil || uint64(len(podList.Items)) != assert.Count {
-		return fmt.Errorf("found %d pods of class %s and phase: %s, but %d expected", len(podList.Items), assert.Class, assert.PodPhase, assert.Count)
+	if assert.Unschedulable {
+		pods, err = r.unschedulablePods(pods, assert.Reason)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if assert.NodeClass != "" {
+		pods, err = r.podsOnNodeClass(pods, assert.NodeClass)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	return pods, cached, nil
+}
 
-	return nil
+// Filters out the pods and nodes of other runs. See inRun.
+
+func (r *runner) podsInRun(pods []*corev1.Pod) []*corev1.Pod {
+	result := []*corev1.Pod{}
+	for _, pod := range pods {
+		if inRun(pod.Labels, r.runID) {
+			result = append(result, pod)
+		}
+	}
+	return result
+}
+
+func (r *runner) podItemsInRun(pods []corev1.Pod) []corev1.Pod {
+	result := []corev1.Pod{}
+	for _, pod := range pods {
+		if inRun(pod.Labels, r.runID) {
+			result = append(result, pod)
+		}
+	}
+	return result
+}
+
+func (r *runner) nodesInRun(nodes []*corev1.Node) []*corev1.Node {
+	result := []*corev1.Node{}
+	for _, n := range nodes {
+		if inRun(n.Labels, r.runID) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (r *runner) nodeItemsInRun(nodes []corev1.Node) []corev1.Node {
+	result := []corev1.Node{}
+	for _, n := range nodes {
+		if inRun(n.Labels, r.runID) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// Filters the supplied pods down to the ones bound to a node of the given
+// class, resolving each pod's spec.nodeName to the node's class label.
+func (r *runner) podsOnNodeClass(pods []*corev1.Pod, class config.Class) ([]*corev1.Pod, error) {
+	nodes, err := r.cache.nodeLister.List(labels.SelectorFromSet(labels.Set{"np.class": string(class)}))
+	if err != nil {
+		return nil, err
+	}
+	nodeNames := map[string]bool{}
+	for _, n := range r.nodesInRun(nodes) {
+		nodeNames[n.Name] = true
+	}
+
+	result := []*corev1.Pod{}
+	for _, pod := range pods {
+		if nodeNames[pod.Spec.NodeName] {
+			result = append(result, pod)
+		}
+	}
+	return result, nil
+}
+
+// Filters the supplied pods down to the ones the scheduler rejected, as
+// reported by their PodScheduled condition. If a reason is given, it must
+// appear in the condition message or in one of the pod's FailedScheduling
+// events (compared case-insensitively).
+func (r *runner) unschedulablePods(pods []*corev1.Pod, reason string) ([]*corev1.Pod, error) {
+	eventMessages := map[types.UID][]string{}
+	if reason != "" {
+		events, err := r.cache.eventLister.Events(r.namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			if ev.InvolvedObject.Kind != "Pod" || ev.Reason != "FailedScheduling" {
+				continue
+			}
+			uid := ev.InvolvedObject.UID
+			eventMessages[uid] = append(eventMessages[uid], ev.Message)
+		}
+	}
+
+	result := []*corev1.Pod{}
+	for _, pod := range pods {
+		for _, c := range pod.Status.Conditions {
+			if c.Type != corev1.PodScheduled || c.Status != corev1.ConditionFalse || c.Reason != corev1.PodReasonUnschedulable {
+				continue
+			}
+			messages := append([]string{c.Message}, eventMessages[pod.UID]...)
+			if reason == "" || containsReason(messages, reason) {
+				result = append(result, pod)
+			}
+			break
+		}
+	}
+	return result, nil
+}
+
+func containsReason(messages []string, reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, m := range messages {
+		if strings.Contains(strings.ToLower(m), reason) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *runner) checkIfAPIAvailable(gvk *schema.GroupVersionKind) error {
@@ -171,48 +468,116 @@ func (r *runner) RunAssert(step *config.Step) error {
 		return fmt.Errorf("there is no assert in this step.")
 	}
 
-	backoffWait := wait.Backoff{
-		Duration: 1 * time.Second,
-		Factor:   1,
-		Steps:    int(step.Assert.Delay.Seconds()),
+	var check func() error
+	if step.Assert.GVK != nil {
+		check = func() error { return r.checkIfAPIAvailable(step.Assert.GVK) }
+	} else {
+		switch step.Assert.Object {
+		case config.Node:
+			check = func() error { return r.assertNode(step.Assert) }
+		case config.Pod:
+			check = func() error { return r.assertPod(step.Assert) }
+		default:
+			return fmt.Errorf("assert object: %s not supported", step.Assert.Object)
+		}
 	}
 
+	// API availability is not part of the informer cache, so it is polled.
+	var poll time.Duration
 	if step.Assert.GVK != nil {
+		poll = apiPollInterval
+	}
 
-		err := r.checkIfAPIAvailable(step.Assert.GVK)
-		for backoffWait.Steps > 0 {
-			if err == nil {
-				break
-			}
-			time.Sleep(backoffWait.Step())
-			err = r.checkIfAPIAvailable(step.Assert.GVK)
+	var err error
+	if step.Assert.Duration > 0 {
+		err = r.assertConsistently(check, step.Assert.Duration, poll)
+	} else {
+		err = r.assertEventually(check, step.Assert.Delay, poll)
+	}
+	if err != nil && step.Assert.GVK == nil {
+		return r.diagnose(step, err)
+	}
+	return err
+}
+
+// Adds a summary of the cluster state to the error of a failed pod or node
+// assert, and writes the full diagnostics to the directory of the step.
+func (r *runner) diagnose(step *config.Step, assertErr error) error {
+	var matched, candidates []*corev1.Pod
+	if step.Assert.Object == config.Pod {
+		var err error
+		if matched, candidates, err = r.podsMatching(step.Assert); err != nil {
+			log.WithFields(log.Fields{"error": err.Error()}).Warn("failed to collect diagnostics")
+			return assertErr
+		}
+	}
+	diagnostics, err := r.collectDiagnostics(step.String(), matched, candidates)
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Warn("failed to collect diagnostics")
+		return assertErr
+	}
+	if r.artifactsDir != "" && r.current != nil {
+		dir := filepath.Join(r.artifactsDir, stepDir(r.current))
+		if err := diagnostics.write(dir); err != nil {
+			log.WithFields(log.Fields{"dir": dir, "error": err.Error()}).Warn("failed to write diagnostics")
+		} else {
+			r.current.Artifacts = dir
 		}
-		return err
 	}
+	return fmt.Errorf("%s (%s)", assertErr.Error(), diagnostics.Summary())
+}
 
-	switch step.Assert.Object {
-	case config.Node:
-		err := r.assertNode(step.Assert)
-		for backoffWait.Steps > 0 {
-			err = r.assertNode(step.Assert)
-			if err == nil {
-				break
-			}
-			time.Sleep(backoffWait.Step())
+// Re-evaluates the check whenever the cache changes (and every poll
+// interval, if non-zero) until it succeeds or the delay has passed,
+// returning the last error.
+func (r *runner) assertEventually(check func() error, delay time.Duration, poll time.Duration) error {
+	timeout := time.After(delay)
+	tick, stopTicker := ticker(poll)
+	defer stopTicker()
+
+	err := check()
+	for err != nil {
+		select {
+		case <-r.cache.changed:
+		case <-tick:
+		case <-timeout:
+			return check()
 		}
-		return err
-	case config.Pod:
-		err := r.assertPod(step.Assert)
-		for backoffWait.Steps > 0 {
-			err = r.assertPod(step.Assert)
-			if err == nil {
-				break
-			}
-			time.Sleep(backoffWait.Step())
+		err = check()
+	}
+	return nil
+}
+
+// Re-evaluates the check whenever the cache changes (and every poll
+// interval, if non-zero) for the whole duration, failing on the first
+// evaluation where it does not hold.
+func (r *runner) assertConsistently(check func() error, duration time.Duration, poll time.Duration) error {
+	start := time.Now()
+	deadline := time.After(duration)
+	tick, stopTicker := ticker(poll)
+	defer stopTicker()
+
+	for {
+		if err := check(); err != nil {
+			return fmt.Errorf("assert did not hold for %s (violated after %s): %s", duration, time.Since(start).Round(time.Millisecond), err.Error())
 		}
-		return err
+		select {
+		case <-r.cache.changed:
+		case <-tick:
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+// Returns a ticker channel for the interval, or a nil channel that never
+// fires if the interval is zero.
+func ticker(interval time.Duration) (<-chan time.Time, func()) {
+	if interval <= 0 {
+		return nil, func() {}
 	}
-	return fmt.Errorf("assert object: %s not supported", step.Assert.Object)
+	t := time.NewTicker(interval)
+	return t.C, t.Stop
 }
 
 func (r *runner) createNode(create *config.CreateStep) error {
@@ -223,16 +588,20 @@ func (r *runner) createNode(create *config.CreateStep) error {
 	}
 	for _, class := range r.nodeConfig.NodeClasses {
 		if config.Class(class.Name) == create.Class {
+			if err := r.nodes.Start(); err != nil {
+				return err
+			}
+			created := []string{}
 			for i := uint64(0); i < create.Count; i++ {
-				nodeName := fmt.Sprintf("%s-%d", class.Name, i)
-				n := node.NewFakeNode(nodeName, class.Name, class.Labels, class.Resources)
-				err := n.Start(r.client)
+				nodeName := fmt.Sprintf("%s-%s", class.Name, utilrand.String(8))
+				n := node.NewFakeNode(nodeName, class.Name, withRun(class.Labels, r.runID), class.Resources, node.DefaultHeartbeat, class.StartupLatencyOr(0))
+				err := r.nodes.Add(n)
 				if err != nil {
 					return fmt.Errorf("could not create node of class: %s, err: %s", create.Class, err.Error())
 				}
-				r.gcNodes[nodeName] = true
+				created = append(created, nodeName)
 			}
-			return nil
+			return r.cache.waitFor(r.cache.nodesCreated(created))
 		}
 	}
 	return fmt.Errorf("class: %s not found in the node config", create.Class)
@@ -247,22 +616,24 @@ func (r *runner) createPod(create *config.CreateStep) error {
 	// Check if podConfig has the specified class
 	for _, class := range r.podConfig.PodClasses {
 		if config.Class(class.Name) == create.Class {
+			created := []string{}
 			for i := uint64(0); i < create.Count; i++ {
 				// Create the pod
-				podName := fmt.Sprintf("%s-%d", class.Name, i)
 				pod := &corev1.Pod{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:   podName,
-						Labels: class.Labels,
+						GenerateName: class.Name + "-",
+						Labels:       withRun(class.Labels, r.runID),
 					},
 					Spec: class.Spec,
 				}
-				if _, err := podClient.Create(pod); err != nil {
+				pod, err := podClient.Create(pod)
+				if err != nil {
 					return err
 				}
-				r.gcPods[podName] = true
+				r.gcPods[pod.Name] = true
+				created = append(created, pod.Name)
 			}
-			return nil
+			return r.cache.waitFor(r.cache.podsCreated(r.namespace, created))
 		}
 	}
 	return fmt.Errorf("class: %s not found in the pod config", create.Class)
@@ -270,9 +641,31 @@ func (r *runner) createPod(create *config.CreateStep) error {
 
 func (r *runner) createObject(create *config.CreateStep) error {
 	// Supported grammar: "create" <count> ( <class> <object> | instance[s] of <path/to/yaml/file> )
-	create.YamlPath = path.Join(r.workingDir, create.YamlPath)
-	r.gcObjects[create.YamlPath] = true
-	return r.dynamicClient.Create(create.YamlPath)
+	// Namespaced instances get generated names, so that they can coexist.
+	yamlPath := path.Join(r.workingDir, create.YamlPath)
+	count := int(create.Count)
+	if count < 1 {
+		count = 1
+	}
+	if count > 1 {
+		// Cluster-scoped objects keep the name in the file, so only one
+		// instance can exist
+		namespaced, err := r.dynamicClient.Namespaced(yamlPath)
+		if err != nil {
+			return err
+		}
+		if !namespaced {
+			return fmt.Errorf("cannot create %d instances of %s: its kind is cluster-scoped, so only one instance can exist", count, create.YamlPath)
+		}
+	}
+	for i := 0; i < count; i++ {
+		name, err := r.dynamicClient.Create(yamlPath, map[string]string{RunLabel: r.runID})
+		if err != nil {
+			return err
+		}
+		r.gcObjects[yamlPath] = append(r.gcObjects[yamlPath], name)
+	}
+	return nil
 }
 
 func (r *runner) RunCreate(step *config.Step) error {
@@ -307,6 +700,7 @@ func (r *runner) changePod(change *config.ChangeStep) error {
 	if err != nil {
 		return err
 	}
+	pods.Items = r.podItemsInRun(pods.Items)
 
 	if len(pods.Items) == 0 {
 		return fmt.Errorf("found 0 pods of class: %s and phase: %s, expected: %d", change.Class, change.FromPodPhase, change.Count)
@@ -319,6 +713,7 @@ func (r *runner) changePod(change *config.ChangeStep) error {
 	// Get a slice
 
 	podClient := r.client.CoreV1().Pods(r.namespace)
+	previousVersions := map[string]string{}
 	for i := uint64(0); i < change.Count; i++ {
 		pod := pods.Items[i]
 		// Copy pod
@@ -344,8 +739,9 @@ func (r *runner) changePod(change *config.ChangeStep) error {
 		if err != nil {
 			return err
 		}
+		previousVersions[pod.Name] = pod.ResourceVersion
 	}
-	return nil
+	return r.cache.waitFor(r.cache.podsUpdated(r.namespace, previousVersions))
 }
 
 func (r *runner) RunChange(step *config.Step) error {
@@ -367,19 +763,31 @@ func (r *runner) deleteNode(del *config.DeleteStep) error {
 	if err != nil {
 		return fmt.Errorf("no nodes found for class: %s", del.Class)
 	}
+	nodes.Items = r.nodeItemsInRun(nodes.Items)
 	if uint64(len(nodes.Items)) < del.Count {
 		return fmt.Errorf("found %d nodes of class: %s, but expected: %d", len(nodes.Items), del.Class, del.Count)
 	}
 
+	managed := map[string]bool{}
+	for _, n := range r.nodes.Nodes() {
+		managed[n.Name()] = true
+	}
+	deleted := []string{}
 	for i := uint64(0); i < del.Count; i++ {
-		err = r.client.CoreV1().Nodes().Delete(nodes.Items[i].Name, &metav1.DeleteOptions{})
+		name := nodes.Items[i].Name
+		// Nodes created by this runner also stop being simulated
+		if managed[name] {
+			err = r.nodes.Delete(name)
+		} else {
+			err = r.client.CoreV1().Nodes().Delete(name, &metav1.DeleteOptions{})
+		}
 		if err != nil {
 			return err
 		}
-		delete(r.gcNodes, nodes.Items[i].Name)
+		deleted = append(deleted, name)
 	}
 
-	return nil
+	return r.cache.waitFor(r.cache.nodesDeleted(deleted))
 }
 
 func (r *runner) deletePod(del *config.DeleteStep) error {
@@ -390,24 +798,46 @@ func (r *runner) deletePod(del *config.DeleteStep) error {
 	if err != nil {
 		return fmt.Errorf("no pods found for class: %s", del.Class)
 	}
+	pods.Items = r.podItemsInRun(pods.Items)
 	if uint64(len(pods.Items)) < del.Count {
 		return fmt.Errorf("found %d pods of class: %s, but expected: %d", len(pods.Items), del.Class, del.Count)
 	}
 
+	deleted := []string{}
 	for i := uint64(0); i < del.Count; i++ {
 		err = r.client.CoreV1().Pods(r.namespace).Delete(pods.Items[i].Name, &metav1.DeleteOptions{})
 		if err != nil {
 			return err
 		}
 		delete(r.gcPods, pods.Items[i].Name)
+		deleted = append(deleted, pods.Items[i].Name)
 	}
-	return nil
+	return r.cache.waitFor(r.cache.podsDeleted(r.namespace, deleted))
 }
 
 func (r *runner) deleteObject(del *config.DeleteStep) error {
-	del.YamlPath = path.Join(r.workingDir, del.YamlPath)
-	delete(r.gcObjects, del.YamlPath)
-	return r.dynamicClient.Delete(del.YamlPath)
+	// Deletes the oldest instances this run created from the file, or the
+	// object named in the file if the run created none.
+	yamlPath := path.Join(r.workingDir, del.YamlPath)
+	names := r.gcObjects[yamlPath]
+	count := int(del.Count)
+	if count < 1 {
+		count = 1
+	}
+	if len(names) == 0 && count == 1 {
+		return r.dynamicClient.Delete(yamlPath, "")
+	}
+	if len(names) < count {
+		return fmt.Errorf("found %d instances of %s created by this run, but expected: %d", len(names), del.YamlPath, count)
+	}
+	for ; count > 0; count-- {
+		if err := r.dynamicClient.Delete(yamlPath, names[0]); err != nil {
+			return err
+		}
+		names = names[1:]
+		r.gcObjects[yamlPath] = names
+	}
+	return nil
 }
 
 func (r *runner) RunDelete(step *config.Step) error {
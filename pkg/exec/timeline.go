@@ -0,0 +1,119 @@
+package exec
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodTimeline records when a pod reached each stage of its lifecycle, as
+// observed by the runner's informer cache. Stages the pod did not reach are
+// zero.
+type PodTimeline struct {
+	Namespace string
+	Name      string
+	Class     string
+	Node      string
+	Created   time.Time
+	Scheduled time.Time
+	Running   time.Time
+	// Succeeded, failed or deleted
+	Finished time.Time
+	Phase    corev1.PodPhase
+	Deleted  bool
+	// Observed while the cache synced, i.e. created before the run
+	PreExisting bool
+}
+
+// Tracks the lifecycle of every pod the informer cache observes.
+type podTracker struct {
+	mu     sync.Mutex
+	pods   map[types.UID]*PodTimeline
+	now    func() time.Time
+	synced bool
+}
+
+func newPodTracker() *podTracker {
+	return &podTracker{pods: map[types.UID]*PodTimeline{}, now: time.Now}
+}
+
+func (t *podTracker) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { t.observe(obj, false) },
+		UpdateFunc: func(oldObj, newObj interface{}) { t.observe(newObj, false) },
+		DeleteFunc: func(obj interface{}) { t.observe(obj, true) },
+	}
+}
+
+func (t *podTracker) observe(obj interface{}, deleted bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	timeline, ok := t.pods[pod.UID]
+	if !ok {
+		timeline = &PodTimeline{
+			Namespace:   pod.Namespace,
+			Name:        pod.Name,
+			Class:       pod.Labels["np.class"],
+			Created:     now,
+			PreExisting: !t.synced,
+		}
+		t.pods[pod.UID] = timeline
+	}
+
+	timeline.Phase = pod.Status.Phase
+	if pod.Spec.NodeName != "" {
+		timeline.Node = pod.Spec.NodeName
+		if timeline.Scheduled.IsZero() {
+			timeline.Scheduled = now
+		}
+	}
+	if pod.Status.Phase == corev1.PodRunning && timeline.Running.IsZero() {
+		timeline.Running = now
+	}
+	terminal := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+	if (terminal || deleted) && timeline.Finished.IsZero() {
+		timeline.Finished = now
+	}
+	if deleted {
+		timeline.Deleted = true
+	}
+}
+
+// Marks the end of the initial listing; pods observed from now on were
+// created during the run.
+func (t *podTracker) markSynced() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.synced = true
+}
+
+// Returns a copy of all timelines, ordered by creation.
+func (t *podTracker) timelines() []*PodTimeline {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timelines := []*PodTimeline{}
+	for _, timeline := range t.pods {
+		copied := *timeline
+		timelines = append(timelines, &copied)
+	}
+	sort.SliceStable(timelines, func(i, j int) bool {
+		if timelines[i].Created.Equal(timelines[j].Created) {
+			return timelines[i].Name < timelines[j].Name
+		}
+		return timelines[i].Created.Before(timelines[j].Created)
+	})
+	return timelines
+}
@@ -0,0 +1,245 @@
+package exec
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Default interval at which node utilization is sampled during a scenario.
+const utilizationSampleInterval = 1 * time.Second
+
+// ResourceList maps resource names to amounts: millicores for cpu, and the
+// base unit (bytes, pods, devices) for everything else.
+type ResourceList map[string]int64
+
+func amount(name corev1.ResourceName, q resource.Quantity) int64 {
+	if name == corev1.ResourceCPU {
+		return q.MilliValue()
+	}
+	return q.Value()
+}
+
+// NodeUtilization is the resources requested by the pods bound to a node,
+// against the node's allocatable resources. The pods resource counts the
+// pods themselves.
+type NodeUtilization struct {
+	Name        string       `json:"name"`
+	Class       string       `json:"class"`
+	Allocated   ResourceList `json:"allocated"`
+	Allocatable ResourceList `json:"allocatable"`
+}
+
+func (n NodeUtilization) Free() ResourceList {
+	free := ResourceList{}
+	for name, allocatable := range n.Allocatable {
+		free[name] = allocatable - n.Allocated[name]
+	}
+	return free
+}
+
+// GroupUtilization aggregates the nodes of a class, or of the whole cluster.
+type GroupUtilization struct {
+	// The node class, or empty for the whole cluster
+	Class       string       `json:"class"`
+	Nodes       int          `json:"nodes"`
+	Allocated   ResourceList `json:"allocated"`
+	Allocatable ResourceList `json:"allocatable"`
+	// The most of each resource free on a single node, i.e. the largest
+	// request that still fits
+	LargestFree ResourceList `json:"largestFree"`
+	// Free resources on nodes that have run out of another resource, which
+	// no pod can use until that resource is released
+	Stranded ResourceList `json:"stranded"`
+}
+
+type UtilizationSample struct {
+	Time  time.Time         `json:"time"`
+	Nodes []NodeUtilization `json:"nodes"`
+}
+
+// Classes returns the utilization of each node class, ordered by class.
+func (s *UtilizationSample) Classes() []GroupUtilization {
+	byClass := map[string][]NodeUtilization{}
+	for _, n := range s.Nodes {
+		byClass[n.Class] = append(byClass[n.Class], n)
+	}
+	classes := []string{}
+	for class := range byClass {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	groups := []GroupUtilization{}
+	for _, class := range classes {
+		groups = append(groups, groupUtilization(class, byClass[class]))
+	}
+	return groups
+}
+
+// Cluster returns the utilization of all nodes.
+func (s *UtilizationSample) Cluster() GroupUtilization {
+	return groupUtilization("", s.Nodes)
+}
+
+func groupUtilization(class string, nodes []NodeUtilization) GroupUtilization {
+	g := GroupUtilization{
+		Class:       class,
+		Nodes:       len(nodes),
+		Allocated:   ResourceList{},
+		Allocatable: ResourceList{},
+		LargestFree: ResourceList{},
+		Stranded:    ResourceList{},
+	}
+	for _, n := range nodes {
+		free := n.Free()
+		for name, allocatable := range n.Allocatable {
+			g.Allocatable[name] += allocatable
+			g.Allocated[name] += n.Allocated[name]
+			if largest, ok := g.LargestFree[name]; !ok || free[name] > largest {
+				g.LargestFree[name] = free[name]
+			}
+			if _, ok := g.Stranded[name]; !ok {
+				g.Stranded[name] = 0
+			}
+			if free[name] > 0 && exhausted(free, name) {
+				g.Stranded[name] += free[name]
+			}
+		}
+	}
+	return g
+}
+
+// Whether any resource other than the given one has nothing left.
+func exhausted(free ResourceList, except string) bool {
+	for name, amount := range free {
+		if name != except && amount <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Samples node utilization from the informer cache until stopped.
+type utilizationSampler struct {
+	cache    *clusterCache
+	interval time.Duration
+	mu       sync.Mutex
+	samples  []*UtilizationSample
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newUtilizationSampler(c *clusterCache, interval time.Duration) *utilizationSampler {
+	return &utilizationSampler{
+		cache:    c,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *utilizationSampler) start() {
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		s.sample()
+		for {
+			select {
+			case <-ticker.C:
+				s.sample()
+			case <-s.stop:
+				s.sample()
+				return
+			}
+		}
+	}()
+}
+
+// Stops sampling, after taking a final sample, and returns all samples.
+func (s *utilizationSampler) shutdown() []*UtilizationSample {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples
+}
+
+func (s *utilizationSampler) sample() {
+	nodes, err := s.cache.nodeLister.List(labels.Everything())
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Debug("failed to sample node utilization")
+		return
+	}
+	pods, err := s.cache.boundPodLister.List(labels.Everything())
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Debug("failed to sample node utilization")
+		return
+	}
+	sample := utilizationOf(time.Now(), nodes, pods)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+}
+
+func utilizationOf(now time.Time, nodes []*corev1.Node, pods []*corev1.Pod) *UtilizationSample {
+	byNode := map[string]*NodeUtilization{}
+	for _, n := range nodes {
+		u := &NodeUtilization{
+			Name:        n.Name,
+			Class:       n.Labels["np.class"],
+			Allocated:   ResourceList{},
+			Allocatable: ResourceList{},
+		}
+		for name, q := range n.Status.Allocatable {
+			u.Allocatable[string(name)] = amount(name, q)
+			u.Allocated[string(name)] = 0
+		}
+		byNode[n.Name] = u
+	}
+	for _, pod := range pods {
+		u, ok := byNode[pod.Spec.NodeName]
+		if !ok || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		u.Allocated[string(corev1.ResourcePods)]++
+		for name, requested := range podRequests(pod) {
+			u.Allocated[name] += requested
+		}
+	}
+
+	sample := &UtilizationSample{Time: now}
+	for _, u := range byNode {
+		sample.Nodes = append(sample.Nodes, *u)
+	}
+	sort.Slice(sample.Nodes, func(i, j int) bool { return sample.Nodes[i].Name < sample.Nodes[j].Name })
+	return sample
+}
+
+// Returns the effective requests of the pod, as the scheduler counts them:
+// for each resource, the sum over the containers, or the largest request of
+// an init container if that is more, since init containers run one at a
+// time before the containers.
+func podRequests(pod *corev1.Pod) ResourceList {
+	requests := ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, q := range container.Resources.Requests {
+			requests[string(name)] += amount(name, q)
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, q := range container.Resources.Requests {
+			if a := amount(name, q); a > requests[string(name)] {
+				requests[string(name)] = a
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,157 @@
+package exec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_utilizationOf(t *testing.T) {
+	node := func(name, class, cpu, memory, pods string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"np.class": class}},
+			Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+				corev1.ResourcePods:   resource.MustParse(pods),
+			}},
+		}
+	}
+	pod := func(node, cpu, memory string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{NodeName: node, Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}}},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		desc     string
+		nodes    []*corev1.Node
+		pods     []*corev1.Pod
+		nodeUtil []NodeUtilization
+		classes  []GroupUtilization
+		cluster  GroupUtilization
+	}{
+		{
+			desc:     "no nodes",
+			nodeUtil: nil,
+			classes:  []GroupUtilization{},
+			cluster: GroupUtilization{Allocated: ResourceList{}, Allocatable: ResourceList{},
+				LargestFree: ResourceList{}, Stranded: ResourceList{}},
+		},
+		{
+			desc: "cpu exhausted on one node strands its memory",
+			nodes: []*corev1.Node{
+				node("n2", "small", "2", "4Gi", "10"),
+				node("n1", "small", "2", "4Gi", "10"),
+				node("n3", "large", "8", "16Gi", "10"),
+			},
+			pods: []*corev1.Pod{
+				pod("n1", "1", "1Gi", corev1.PodRunning),
+				pod("n1", "1000m", "1Gi", corev1.PodRunning),
+				pod("n2", "500m", "1Gi", corev1.PodPending),
+				// Finished and unbound pods hold no resources
+				pod("n2", "1", "1Gi", corev1.PodSucceeded),
+				pod("", "1", "1Gi", corev1.PodPending),
+			},
+			nodeUtil: []NodeUtilization{
+				{Name: "n1", Class: "small",
+					Allocated:   ResourceList{"cpu": 2000, "memory": 2 << 30, "pods": 2},
+					Allocatable: ResourceList{"cpu": 2000, "memory": 4 << 30, "pods": 10}},
+				{Name: "n2", Class: "small",
+					Allocated:   ResourceList{"cpu": 500, "memory": 1 << 30, "pods": 1},
+					Allocatable: ResourceList{"cpu": 2000, "memory": 4 << 30, "pods": 10}},
+				{Name: "n3", Class: "large",
+					Allocated:   ResourceList{"cpu": 0, "memory": 0, "pods": 0},
+					Allocatable: ResourceList{"cpu": 8000, "memory": 16 << 30, "pods": 10}},
+			},
+			classes: []GroupUtilization{
+				{Class: "large", Nodes: 1,
+					Allocated:   ResourceList{"cpu": 0, "memory": 0, "pods": 0},
+					Allocatable: ResourceList{"cpu": 8000, "memory": 16 << 30, "pods": 10},
+					LargestFree: ResourceList{"cpu": 8000, "memory": 16 << 30, "pods": 10},
+					Stranded:    ResourceList{"cpu": 0, "memory": 0, "pods": 0}},
+				{Class: "small", Nodes: 2,
+					Allocated:   ResourceList{"cpu": 2500, "memory": 3 << 30, "pods": 3},
+					Allocatable: ResourceList{"cpu": 4000, "memory": 8 << 30, "pods": 20},
+					LargestFree: ResourceList{"cpu": 1500, "memory": 3 << 30, "pods": 9},
+					Stranded:    ResourceList{"cpu": 0, "memory": 2 << 30, "pods": 8}},
+			},
+			cluster: GroupUtilization{Nodes: 3,
+				Allocated:   ResourceList{"cpu": 2500, "memory": 3 << 30, "pods": 3},
+				Allocatable: ResourceList{"cpu": 12000, "memory": 24 << 30, "pods": 30},
+				LargestFree: ResourceList{"cpu": 8000, "memory": 16 << 30, "pods": 10},
+				Stranded:    ResourceList{"cpu": 0, "memory": 2 << 30, "pods": 8}},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		sample := utilizationOf(now, c.nodes, c.pods)
+		if !sample.Time.Equal(now) {
+			t.Fatalf("(case: %s) expected sample at %s, got %s", c.desc, now, sample.Time)
+		}
+		if !reflect.DeepEqual(c.nodeUtil, sample.Nodes) {
+			t.Fatalf("(case: %s) expected nodes:\n%+v\ngot:\n%+v", c.desc, c.nodeUtil, sample.Nodes)
+		}
+		if classes := sample.Classes(); !reflect.DeepEqual(c.classes, classes) {
+			t.Fatalf("(case: %s) expected classes:\n%+v\ngot:\n%+v", c.desc, c.classes, classes)
+		}
+		if cluster := sample.Cluster(); !reflect.DeepEqual(c.cluster, cluster) {
+			t.Fatalf("(case: %s) expected cluster:\n%+v\ngot:\n%+v", c.desc, c.cluster, cluster)
+		}
+	}
+}
+
+func Test_podRequests(t *testing.T) {
+	container := func(cpu, memory string) corev1.Container {
+		return corev1.Container{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}}
+	}
+
+	cases := []struct {
+		desc       string
+		containers []corev1.Container
+		init       []corev1.Container
+		expected   ResourceList
+	}{
+		{
+			desc:       "containers are summed",
+			containers: []corev1.Container{container("500m", "1Gi"), container("1", "1Gi")},
+			expected:   ResourceList{"cpu": 1500, "memory": 2 << 30},
+		},
+		{
+			desc:       "smaller init containers do not count",
+			containers: []corev1.Container{container("500m", "1Gi"), container("1", "1Gi")},
+			init:       []corev1.Container{container("1", "512Mi"), container("250m", "1Gi")},
+			expected:   ResourceList{"cpu": 1500, "memory": 2 << 30},
+		},
+		{
+			desc:       "the largest init container counts if it is more",
+			containers: []corev1.Container{container("500m", "1Gi")},
+			init:       []corev1.Container{container("2", "512Mi"), container("250m", "3Gi")},
+			expected:   ResourceList{"cpu": 2000, "memory": 3 << 30},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: c.containers, InitContainers: c.init}}
+		if actual := podRequests(pod); !reflect.DeepEqual(c.expected, actual) {
+			t.Fatalf("(case: %s) expected requests %v, got %v", c.desc, c.expected, actual)
+		}
+	}
+}
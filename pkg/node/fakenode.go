@@ -1,14 +1,13 @@
 package node
 
 import (
-	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/IntelAI/nodus/pkg/config"
@@ -16,25 +15,37 @@ import (
 
 const NodeClassLabel = "np.class"
 
-func NewFakeNode(name string, class string, labels map[string]string, resources config.NodeResources) FakeNode {
+// Pods bound to the node stay pending for startupLatency, unless they set
+// their own in the np.startupLatency label.
+func NewFakeNode(name string, class string, labels map[string]string, resources config.NodeResources, heartbeat Heartbeat, startupLatency time.Duration) FakeNode {
 	// Add class to node labels
 	labels[NodeClassLabel] = class
 
 	return &fakeNode{
-		name:      name,
-		class:     class,
-		labels:    labels,
-		resources: resources,
-		pods:      NewPodSet(),
-		done:      make(chan struct{}),
+		name:           name,
+		class:          class,
+		labels:         labels,
+		resources:      resources,
+		heartbeat:      heartbeat,
+		startupLatency: startupLatency,
+		pods:           NewPodSet(),
+		transitions:    newTransitionQueue(),
+		done:           make(chan struct{}),
 	}
 }
 
+// FakeNode is a node simulated by a NodeManager, which starts it, feeds it
+// the pods bound to it and stops it.
 type FakeNode interface {
 	Name() string
 	Class() string
-	Start(client *kubernetes.Clientset) error
-	Stop() error
+	start(client *kubernetes.Clientset) error
+	stop()
+	failPods()
+	unregister() error
+	podAdded(pod *v1.Pod)
+	podModified(pod *v1.Pod)
+	podDeleted(pod *v1.Pod)
 }
 
 type fakeNode struct {
@@ -44,9 +55,16 @@ type fakeNode struct {
 	node      *v1.Node
 	labels    map[string]string
 	resources config.NodeResources
-	pods      PodSet
-	podWatch  watch.Interface
-	done      chan struct{}
+	heartbeat Heartbeat
+	// How long pods stay pending unless they set np.startupLatency
+	startupLatency time.Duration
+	pods           PodSet
+	// The next phase transition of each pod
+	transitions *transitionQueue
+	done        chan struct{}
+	stopOnce    sync.Once
+	// Whether the last lease renewal failed, to warn only once
+	leaseFailed bool
 }
 
 func (n *fakeNode) Name() string {
@@ -57,20 +75,20 @@ func (n *fakeNode) Class() string {
 	return n.class
 }
 
-func (n *fakeNode) Start(client *kubernetes.Clientset) error {
+func (n *fakeNode) start(client *kubernetes.Clientset) error {
 	n.client = client
-	err := n.startWatchingPods()
-	if err != nil {
+	go n.updatePods()
+	if err := n.register(); err != nil {
+		n.stop()
 		return err
 	}
-	n.startUpdatingPods()
-	return n.register()
+	n.startHeartbeats()
+	return nil
 }
 
-func (n *fakeNode) Stop() error {
-	n.podWatch.Stop()
-	n.stopUpdatingPods()
-	return n.unregister()
+// Stops updating pods and posting heartbeats.
+func (n *fakeNode) stop() {
+	n.stopOnce.Do(func() { close(n.done) })
 }
 
 func (n *fakeNode) register() error {
@@ -87,79 +105,32 @@ func (n *fakeNode) register() error {
 	return nil
 }
 
-func (n *fakeNode) startWatchingPods() error {
-	// Only list/watch pods bound to this node
-	lOpts := metav1.ListOptions{
-		Watch:         true,
-		FieldSelector: fmt.Sprintf("spec.nodeName=%s", n.name),
-	}
-	namespace := ""
-	podWatch, err := n.client.CoreV1().Pods(namespace).Watch(lOpts)
-	if err != nil {
-		return err
-	}
-	n.podWatch = podWatch
+// Pod events from the shared informer, updating the local pod cache
+// incrementally. Pods are upserted since the NodeManager may pass a pod more
+// than once when the node is added.
 
-	// Asynchronously consume all watch events
-	go n.consumeWatchEvents()
-	return nil
+func (n *fakeNode) podAdded(pod *v1.Pod) {
+	log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": pod.Status.Phase}).Debug("pod added")
+	n.pods.Update(pod)
+	n.scheduleTransition(pod, time.Now())
 }
 
-// Consumes all events from the pod watch channel, updating
-// the local pod cache incrementally.
-func (n *fakeNode) consumeWatchEvents() {
-	for ev := range n.podWatch.ResultChan() {
-		switch ev.Type {
-		case watch.Added:
-			pod := ev.Object.(*v1.Pod)
-			log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": pod.Status.Phase}).Debug("pod added")
-			n.pods.Add(pod)
-		case watch.Deleted:
-			pod := ev.Object.(*v1.Pod)
-			log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": pod.Status.Phase}).Debug("pod deleted")
-			n.pods.Remove(pod)
-		case watch.Modified:
-			pod := ev.Object.(*v1.Pod)
-			log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": pod.Status.Phase}).Debug("pod modified")
-			// If pod was marked "deleted" in the API, mimic Kubelet finalization
-			// and unblock deleting the pod resource.
-			if pod.ObjectMeta.DeletionTimestamp != nil && *pod.ObjectMeta.DeletionGracePeriodSeconds > 0 {
-				n.finalizeDeletedPod(pod)
-			}
-			n.pods.Update(pod)
-		}
+func (n *fakeNode) podModified(pod *v1.Pod) {
+	log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": pod.Status.Phase}).Debug("pod modified")
+	// If pod was marked "deleted" in the API, mimic Kubelet finalization
+	// and unblock deleting the pod resource. This runs asynchronously so
+	// that it does not hold up the events of other nodes.
+	if pod.ObjectMeta.DeletionTimestamp != nil && *pod.ObjectMeta.DeletionGracePeriodSeconds > 0 {
+		go n.finalizeDeletedPod(pod)
 	}
+	n.pods.Update(pod)
+	n.scheduleTransition(pod, time.Now())
 }
 
-func (n *fakeNode) startUpdatingPods() {
-	go n.updatePods()
-}
-
-func (n *fakeNode) stopUpdatingPods() {
-	close(n.done)
-}
-
-// Periodically inspects the local pod cache for pods that need
-// to have their phase updated: pending => running or running => terminal.
-func (n *fakeNode) updatePods() {
-	updateInterval := 2 * time.Second
-	t := time.NewTimer(updateInterval)
-	for {
-		select {
-		case <-n.done:
-			break
-		case <-t.C:
-			// Move all bound pending pods to phase running
-			pendingPods := n.pods.OfPhase(v1.PodPending)
-			n.tryUpdatePodPhase(v1.PodRunning, pendingPods...)
-			// Move all expired pods to the specified terminal state.
-			for _, pod := range n.pods.Expired() {
-				n.tryUpdatePodPhase(TerminalPhase(pod), pod)
-			}
-			// Reset timer
-			t.Reset(updateInterval)
-		}
-	}
+func (n *fakeNode) podDeleted(pod *v1.Pod) {
+	log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": pod.Status.Phase}).Debug("pod deleted")
+	n.pods.Remove(pod)
+	n.transitions.remove(pod)
 }
 
 // Completes pod deletion by deleting again with no grace period. This mimics
@@ -173,10 +144,14 @@ func (n *fakeNode) finalizeDeletedPod(pod *v1.Pod) {
 	n.client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, opts)
 }
 
-func (n *fakeNode) unregister() error {
-	// Set all nonterminal pods to failed
+// Sets all nonterminal pods to failed.
+func (n *fakeNode) failPods() {
 	ntPods := n.pods.OfPhase(v1.PodPending, v1.PodUnknown, v1.PodRunning)
 	n.tryUpdatePodPhase(v1.PodFailed, ntPods...)
+}
+
+func (n *fakeNode) unregister() error {
+	n.deleteLease()
 
 	// Delete this node immediately
 	gracePeriod := int64(0)
@@ -186,50 +161,56 @@ func (n *fakeNode) unregister() error {
 
 // Updates the list of pods to the desired phase, on a best-effort basis.
 //
-// Note the pod cache is not updated here; the watcher takes care of that
-// when a Modified event is received.
+// Note the pod cache is not updated here; the shared informer takes care of
+// that when a Modified event is received.
 func (n *fakeNode) tryUpdatePodPhase(phase v1.PodPhase, pods ...*v1.Pod) {
 	for _, pod := range pods {
-		originalPhase := pod.Status.Phase
+		n.updatePodPhase(phase, pod)
+	}
+}
 
-		podClient := n.client.CoreV1().Pods(pod.Namespace)
+// Updates the pod to the desired phase, and returns the updated pod.
+func (n *fakeNode) updatePodPhase(phase v1.PodPhase, pod *v1.Pod) (*v1.Pod, error) {
+	originalPhase := pod.Status.Phase
 
-		var copy v1.Pod
-		copy = *pod
-		copy.Status.Phase = phase
+	podClient := n.client.CoreV1().Pods(pod.Namespace)
 
-		// Add initialized and ready conditions for newly "running" pods
-		if originalPhase == v1.PodPending && phase == v1.PodRunning {
-			newConds := readyConds(v1.ConditionTrue)
-			copy.Status.Conditions = append(copy.Status.Conditions, newConds...)
-		}
+	// Pods are shared with the informer cache, so never modified in place
+	copy := pod.DeepCopy()
+	copy.Status.Phase = phase
 
-		// Unset ready conditions for terminal pods
-		if phase == v1.PodSucceeded || phase == v1.PodFailed {
-			newConds := readyConds(v1.ConditionFalse)
-			copy.Status.Conditions = append(copy.Status.Conditions, newConds...)
-		}
-
-		pod, err := podClient.UpdateStatus(&copy)
+	// Add initialized and ready conditions for newly "running" pods
+	if originalPhase == v1.PodPending && phase == v1.PodRunning {
+		newConds := readyConds(v1.ConditionTrue)
+		copy.Status.Conditions = append(copy.Status.Conditions, newConds...)
+	}
 
-		if err != nil {
-			log.WithFields(log.Fields{
-				"node":          n.name,
-				"pod":           pod.Name,
-				"current_phase": pod.Status.Phase,
-				"desired_phase": phase,
-				"error":         err.Error(),
-			}).Warning("unable to patch pod")
-		}
+	// Unset ready conditions for terminal pods
+	if phase == v1.PodSucceeded || phase == v1.PodFailed {
+		newConds := readyConds(v1.ConditionFalse)
+		copy.Status.Conditions = append(copy.Status.Conditions, newConds...)
+	}
 
+	updated, err := podClient.UpdateStatus(copy)
+	if err != nil {
 		log.WithFields(log.Fields{
-			"node":           n.name,
-			"pod":            pod.Name,
-			"original_phase": originalPhase,
-			"current_phase":  pod.Status.Phase,
-			"desired_phase":  phase,
-		}).Debug("updated pod phase")
+			"node":          n.name,
+			"pod":           pod.Name,
+			"current_phase": originalPhase,
+			"desired_phase": phase,
+			"error":         err.Error(),
+		}).Warning("unable to patch pod")
+		return nil, err
 	}
+
+	log.WithFields(log.Fields{
+		"node":           n.name,
+		"pod":            pod.Name,
+		"original_phase": originalPhase,
+		"current_phase":  updated.Status.Phase,
+		"desired_phase":  phase,
+	}).Debug("updated pod phase")
+	return updated, nil
 }
 
 func readyConds(status v1.ConditionStatus) []v1.PodCondition {
@@ -292,12 +273,7 @@ func (n *fakeNode) k8sNode() (*v1.Node, error) {
 			Allocatable: allocatable,
 			Phase:       v1.NodeRunning,
 			Addresses:   []v1.NodeAddress{},
-			Conditions: []v1.NodeCondition{
-				v1.NodeCondition{
-					Type:   v1.NodeReady,
-					Status: v1.ConditionTrue,
-				},
-			},
+			Conditions:  []v1.NodeCondition{readyCondition(metav1.Now())},
 		},
 	}
 
@@ -0,0 +1,177 @@
+package node
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Heartbeat configures how a fake node reports that it is alive, like the
+// kubelet's node status updates and node lease. Zero intervals disable the
+// corresponding heartbeat.
+type Heartbeat struct {
+	// How often the heartbeat of the NodeReady condition is updated
+	StatusInterval time.Duration
+	// How often the node's Lease in kube-node-lease is renewed
+	LeaseInterval time.Duration
+	// The duration recorded in the Lease, after which the node controller
+	// considers the node gone
+	LeaseDuration time.Duration
+}
+
+// DefaultHeartbeat matches the kubelet defaults.
+var DefaultHeartbeat = Heartbeat{
+	StatusInterval: 10 * time.Second,
+	LeaseInterval:  10 * time.Second,
+	LeaseDuration:  40 * time.Second,
+}
+
+func (n *fakeNode) startHeartbeats() {
+	if n.heartbeat.StatusInterval > 0 {
+		go n.every(n.heartbeat.StatusInterval, n.updateStatus)
+	}
+	if n.heartbeat.LeaseInterval > 0 {
+		go n.every(n.heartbeat.LeaseInterval, n.renewLease)
+	}
+}
+
+// Calls f right away and then every interval, until the node stops.
+func (n *fakeNode) every(interval time.Duration, f func()) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	f()
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-t.C:
+			f()
+		}
+	}
+}
+
+// Sets the heartbeat of the node's Ready condition to now.
+func (n *fakeNode) updateStatus() {
+	nodes := n.client.CoreV1().Nodes()
+	node, err := nodes.Get(n.name, metav1.GetOptions{})
+	if err != nil {
+		log.WithFields(log.Fields{"node": n.name, "error": err.Error()}).Warning("unable to get node for heartbeat")
+		return
+	}
+	if _, err := nodes.UpdateStatus(withHeartbeat(node, metav1.Now())); err != nil {
+		// Conflicts with other writers are retried on the next heartbeat
+		log.WithFields(log.Fields{"node": n.name, "error": err.Error()}).Debug("unable to update node status")
+		return
+	}
+	log.WithFields(log.Fields{"node": n.name}).Debug("updated node status")
+}
+
+// Returns a copy of the node whose Ready condition is true, with its
+// heartbeat at now. The transition time only changes if the node was not
+// ready before.
+func withHeartbeat(node *v1.Node, now metav1.Time) *v1.Node {
+	node = node.DeepCopy()
+	for i := range node.Status.Conditions {
+		c := &node.Status.Conditions[i]
+		if c.Type != v1.NodeReady {
+			continue
+		}
+		if c.Status != v1.ConditionTrue {
+			c.LastTransitionTime = now
+		}
+		c.Status = v1.ConditionTrue
+		c.Reason = readyReason
+		c.Message = readyMessage
+		c.LastHeartbeatTime = now
+		return node
+	}
+	node.Status.Conditions = append(node.Status.Conditions, readyCondition(now))
+	return node
+}
+
+const (
+	readyReason  = "KubeletReady"
+	readyMessage = "fake kubelet is posting ready status"
+)
+
+func readyCondition(now metav1.Time) v1.NodeCondition {
+	return v1.NodeCondition{
+		Type:               v1.NodeReady,
+		Status:             v1.ConditionTrue,
+		Reason:             readyReason,
+		Message:            readyMessage,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+}
+
+// Creates or renews the node's Lease.
+func (n *fakeNode) renewLease() {
+	leases := n.client.CoordinationV1beta1().Leases(v1.NamespaceNodeLease)
+	now := metav1.NowMicro()
+	lease, err := leases.Get(n.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = leases.Create(n.nodeLease(now))
+	case err == nil:
+		lease = lease.DeepCopy()
+		lease.Spec = n.nodeLease(now).Spec
+		_, err = leases.Update(lease)
+	}
+	if err != nil {
+		// The namespace only exists if the API server has node leases enabled
+		fields := log.Fields{"node": n.name, "error": err.Error()}
+		if !n.leaseFailed {
+			log.WithFields(fields).Warning("unable to renew node lease")
+			n.leaseFailed = true
+		} else {
+			log.WithFields(fields).Debug("unable to renew node lease")
+		}
+		return
+	}
+	n.leaseFailed = false
+	log.WithFields(log.Fields{"node": n.name}).Debug("renewed node lease")
+}
+
+// The Lease of the node, held by the node and owned by it, so that it is
+// garbage collected with the node.
+func (n *fakeNode) nodeLease(now metav1.MicroTime) *coordinationv1beta1.Lease {
+	holder := n.name
+	duration := int32(n.heartbeat.LeaseDuration / time.Second)
+	lease := &coordinationv1beta1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      n.name,
+			Namespace: v1.NamespaceNodeLease,
+		},
+		Spec: coordinationv1beta1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &now,
+		},
+	}
+	if n.node != nil && n.node.UID != "" {
+		lease.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       n.name,
+			UID:        n.node.UID,
+		}}
+	}
+	return lease
+}
+
+// Deletes the node's Lease, on a best-effort basis, since the garbage
+// collector only runs with the controller manager.
+func (n *fakeNode) deleteLease() {
+	if n.heartbeat.LeaseInterval <= 0 {
+		return
+	}
+	err := n.client.CoordinationV1beta1().Leases(v1.NamespaceNodeLease).Delete(n.name, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.WithFields(log.Fields{"node": n.name, "error": err.Error()}).Debug("unable to delete node lease")
+	}
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_withHeartbeat(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(earlier.Add(10 * time.Second))
+	pressure := v1.NodeCondition{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse, LastHeartbeatTime: earlier}
+
+	cases := []struct {
+		desc       string
+		conditions []v1.NodeCondition
+		expected   []v1.NodeCondition
+	}{
+		{
+			desc:       "no ready condition",
+			conditions: []v1.NodeCondition{pressure},
+			expected:   []v1.NodeCondition{pressure, readyCondition(now)},
+		},
+		{
+			desc: "ready keeps its transition time",
+			conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue, LastHeartbeatTime: earlier, LastTransitionTime: earlier},
+			},
+			expected: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue, Reason: readyReason, Message: readyMessage, LastHeartbeatTime: now, LastTransitionTime: earlier},
+			},
+		},
+		{
+			desc: "marked unknown by the node controller",
+			conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionUnknown, Reason: "NodeStatusUnknown", LastHeartbeatTime: earlier, LastTransitionTime: earlier},
+			},
+			expected: []v1.NodeCondition{readyCondition(now)},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		node := &v1.Node{Status: v1.NodeStatus{Conditions: c.conditions}}
+		original := node.DeepCopy()
+		updated := withHeartbeat(node, now)
+		if !reflect.DeepEqual(c.expected, updated.Status.Conditions) {
+			t.Fatalf("(case: %s) expected conditions:\n%+v\ngot:\n%+v", c.desc, c.expected, updated.Status.Conditions)
+		}
+		if !reflect.DeepEqual(original, node) {
+			t.Fatalf("(case: %s) expected the node to be left alone", c.desc)
+		}
+	}
+}
+
+func Test_nodeLease(t *testing.T) {
+	now := metav1.NewMicroTime(time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC))
+	n := &fakeNode{
+		name:      "large-0",
+		heartbeat: Heartbeat{LeaseInterval: 10 * time.Second, LeaseDuration: 40 * time.Second},
+		node:      &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "large-0", UID: "uid-1"}},
+	}
+	lease := n.nodeLease(now)
+	if lease.Namespace != v1.NamespaceNodeLease || lease.Name != "large-0" {
+		t.Fatalf("expected lease kube-node-lease/large-0, got %s/%s", lease.Namespace, lease.Name)
+	}
+	if *lease.Spec.HolderIdentity != "large-0" || *lease.Spec.LeaseDurationSeconds != 40 || !lease.Spec.RenewTime.Equal(&now) {
+		t.Fatalf("unexpected lease spec: %+v", lease.Spec)
+	}
+	if len(lease.OwnerReferences) != 1 || lease.OwnerReferences[0].UID != "uid-1" || lease.OwnerReferences[0].Kind != "Node" {
+		t.Fatalf("expected the lease to be owned by the node, got %+v", lease.OwnerReferences)
+	}
+}
@@ -0,0 +1,236 @@
+package node
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+)
+
+// How long to wait before retrying a transition whose pod update failed.
+const transitionRetryInterval = time.Second
+
+// A pod's next phase transition: from Pending to Running, or from Running to
+// its terminal phase.
+type transition struct {
+	key  string
+	pod  *v1.Pod
+	from v1.PodPhase
+	at   time.Time
+	// Position in the heap
+	index int
+}
+
+// Min-heap of transitions ordered by time, for container/heap.
+type transitionHeap []*transition
+
+func (h transitionHeap) Len() int           { return len(h) }
+func (h transitionHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h transitionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *transitionHeap) Push(x interface{}) {
+	t := x.(*transition)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *transitionHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return t
+}
+
+// The pending transitions of the pods of a node, with at most one
+// transition per pod.
+type transitionQueue struct {
+	sync.Mutex
+	heap  transitionHeap
+	byKey map[string]*transition
+	// Receives a signal when the earliest transition may have changed
+	wake chan struct{}
+}
+
+func newTransitionQueue() *transitionQueue {
+	return &transitionQueue{
+		byKey: map[string]*transition{},
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Schedules the transition of the pod out of its phase, unless one is
+// already scheduled out of the same phase, in which case only the pod is
+// updated.
+func (q *transitionQueue) schedule(pod *v1.Pod, at time.Time) {
+	q.put(pod, at, false)
+}
+
+// Schedules the transition of the pod out of its phase at the given time,
+// replacing any transition already scheduled.
+func (q *transitionQueue) set(pod *v1.Pod, at time.Time) {
+	q.put(pod, at, true)
+}
+
+func (q *transitionQueue) put(pod *v1.Pod, at time.Time, replace bool) {
+	q.Lock()
+	defer q.Unlock()
+	key := podKey(pod)
+	if t, ok := q.byKey[key]; ok {
+		t.pod = pod
+		if t.from == pod.Status.Phase && !replace {
+			return
+		}
+		t.from = pod.Status.Phase
+		t.at = at
+		heap.Fix(&q.heap, t.index)
+	} else {
+		t := &transition{key: key, pod: pod, from: pod.Status.Phase, at: at}
+		heap.Push(&q.heap, t)
+		q.byKey[key] = t
+	}
+	q.notify()
+}
+
+// Moves the transition of the pod to another time.
+func (q *transitionQueue) reschedule(t *transition, at time.Time) {
+	q.Lock()
+	defer q.Unlock()
+	if q.byKey[t.key] != t {
+		return
+	}
+	t.at = at
+	heap.Fix(&q.heap, t.index)
+}
+
+func (q *transitionQueue) remove(pod *v1.Pod) {
+	q.Lock()
+	defer q.Unlock()
+	if t, ok := q.byKey[podKey(pod)]; ok {
+		heap.Remove(&q.heap, t.index)
+		delete(q.byKey, t.key)
+	}
+}
+
+// Returns the transitions that are due at now, and the time of the next one,
+// or the zero time if there is none. Due transitions are left in the queue
+// until their pod moves on or is removed.
+func (q *transitionQueue) due(now time.Time) ([]*transition, time.Time) {
+	q.Lock()
+	defer q.Unlock()
+	due := []*transition{}
+	for len(q.heap) > 0 && !q.heap[0].at.After(now) {
+		due = append(due, heap.Pop(&q.heap).(*transition))
+	}
+	// Keep them queued, out of the way until they are rescheduled
+	far := now.Add(transitionRetryInterval)
+	for _, t := range due {
+		t.at = far
+		heap.Push(&q.heap, t)
+	}
+	if len(q.heap) == 0 {
+		return due, time.Time{}
+	}
+	return due, q.heap[0].at
+}
+
+func (q *transitionQueue) len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.heap)
+}
+
+func (q *transitionQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Schedules the next phase transition of a pod bound to the node: pending
+// pods start after their startup latency, running pods end at their run
+// duration. Terminal and deleted pods have no transition.
+func (n *fakeNode) scheduleTransition(pod *v1.Pod, now time.Time) {
+	if pod.DeletionTimestamp != nil {
+		n.transitions.remove(pod)
+		return
+	}
+	switch pod.Status.Phase {
+	case v1.PodPending:
+		n.transitions.schedule(pod, now.Add(StartupLatency(pod, n.startupLatency)))
+	case v1.PodRunning:
+		// Pods this node started are scheduled on the exact time they
+		// started, see runTransition. Others, e.g. pods bound before the
+		// node started or changed to running by a scenario, are timed from
+		// their ready condition.
+		n.transitions.schedule(pod, runningSince(pod, now).Add(RunDuration(pod)))
+	default:
+		n.transitions.remove(pod)
+	}
+}
+
+// When the pod became ready, or now if it is not ready.
+func runningSince(pod *v1.Pod, now time.Time) time.Time {
+	if since, ready := readySince(pod); ready {
+		return since
+	}
+	return now
+}
+
+// Moves pods to their next phase when their transition is due, until the
+// node stops.
+func (n *fakeNode) updatePods() {
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+	for {
+		due, next := n.transitions.due(time.Now())
+		for _, tr := range due {
+			n.runTransition(tr)
+		}
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			t.Reset(time.Until(next))
+		}
+		select {
+		case <-n.done:
+			return
+		case <-n.transitions.wake:
+		case <-t.C:
+		}
+	}
+}
+
+func (n *fakeNode) runTransition(t *transition) {
+	pod := t.pod
+	switch t.from {
+	case v1.PodPending:
+		updated, err := n.updatePodPhase(v1.PodRunning, pod)
+		if err != nil {
+			n.transitions.reschedule(t, time.Now().Add(transitionRetryInterval))
+			return
+		}
+		// Time the run from now rather than from the ready condition, which
+		// only has a precision of seconds
+		n.transitions.set(updated, time.Now().Add(RunDuration(pod)))
+	case v1.PodRunning:
+		if _, err := n.updatePodPhase(TerminalPhase(pod), pod); err != nil {
+			n.transitions.reschedule(t, time.Now().Add(transitionRetryInterval))
+			return
+		}
+		n.transitions.remove(pod)
+	default:
+		log.WithFields(log.Fields{"node": n.name, "pod": pod.Name, "phase": t.from}).Debug("no transition")
+		n.transitions.remove(pod)
+	}
+}
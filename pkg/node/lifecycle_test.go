@@ -0,0 +1,150 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func phasePod(name string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func Test_transitionQueue(t *testing.T) {
+	start := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	cases := []struct {
+		desc     string
+		steps    func(q *transitionQueue)
+		now      time.Time
+		expected []string
+		next     time.Time
+	}{
+		{
+			desc:     "empty",
+			steps:    func(q *transitionQueue) {},
+			now:      at(10),
+			expected: []string{},
+		},
+		{
+			desc: "due transitions in time order",
+			steps: func(q *transitionQueue) {
+				q.schedule(phasePod("b", v1.PodPending), at(2))
+				q.schedule(phasePod("c", v1.PodRunning), at(20))
+				q.schedule(phasePod("a", v1.PodPending), at(1))
+			},
+			now:      at(5),
+			expected: []string{"default/a", "default/b"},
+			next:     at(6),
+		},
+		{
+			desc: "scheduling the same phase again keeps the time",
+			steps: func(q *transitionQueue) {
+				q.schedule(phasePod("a", v1.PodRunning), at(1))
+				q.schedule(phasePod("a", v1.PodRunning), at(20))
+			},
+			now:      at(5),
+			expected: []string{"default/a"},
+			next:     at(6),
+		},
+		{
+			desc: "scheduling another phase replaces the time",
+			steps: func(q *transitionQueue) {
+				q.schedule(phasePod("a", v1.PodPending), at(1))
+				q.schedule(phasePod("a", v1.PodRunning), at(20))
+			},
+			now:      at(5),
+			expected: []string{},
+			next:     at(20),
+		},
+		{
+			desc: "set replaces the time",
+			steps: func(q *transitionQueue) {
+				q.schedule(phasePod("a", v1.PodRunning), at(1))
+				q.set(phasePod("a", v1.PodRunning), at(20))
+			},
+			now:      at(5),
+			expected: []string{},
+			next:     at(20),
+		},
+		{
+			desc: "removed pods have no transition",
+			steps: func(q *transitionQueue) {
+				q.schedule(phasePod("a", v1.PodPending), at(1))
+				q.schedule(phasePod("b", v1.PodPending), at(2))
+				q.remove(phasePod("a", v1.PodPending))
+			},
+			now:      at(5),
+			expected: []string{"default/b"},
+			next:     at(6),
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		q := newTransitionQueue()
+		c.steps(q)
+		due, next := q.due(c.now)
+		keys := []string{}
+		for _, tr := range due {
+			keys = append(keys, tr.key)
+		}
+		if !reflect.DeepEqual(c.expected, keys) {
+			t.Fatalf("(case: %s) expected due transitions %v, got %v", c.desc, c.expected, keys)
+		}
+		if !next.Equal(c.next) {
+			t.Fatalf("(case: %s) expected the next transition at %s, got %s", c.desc, c.next, next)
+		}
+	}
+}
+
+func Test_transitionQueue_reschedule(t *testing.T) {
+	start := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := newTransitionQueue()
+	q.schedule(phasePod("a", v1.PodPending), start)
+	due, _ := q.due(start)
+	if len(due) != 1 {
+		t.Fatalf("expected 1 due transition, got %d", len(due))
+	}
+	q.reschedule(due[0], start.Add(3*time.Second))
+	if _, next := q.due(start); !next.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("expected the transition to be rescheduled, next is at %s", next)
+	}
+	q.remove(due[0].pod)
+	q.reschedule(due[0], start)
+	if q.len() != 0 {
+		t.Fatalf("expected rescheduling a removed transition to have no effect, got %d transitions", q.len())
+	}
+}
+
+func TestStartupLatency(t *testing.T) {
+	cases := []struct {
+		desc        string
+		labels      map[string]string
+		nodeDefault time.Duration
+		expected    time.Duration
+	}{
+		{"unset", map[string]string{}, 0, 0},
+		{"set", map[string]string{PodStartupLatencyLabel: "1500ms"}, 0, 1500 * time.Millisecond},
+		{"invalid", map[string]string{PodStartupLatencyLabel: "soon"}, 0, 0},
+		{"negative", map[string]string{PodStartupLatencyLabel: "-1s"}, 0, 0},
+		{"node default", map[string]string{}, 2 * time.Second, 2 * time.Second},
+		{"set overrides the node default", map[string]string{PodStartupLatencyLabel: "0s"}, 2 * time.Second, 0},
+		{"invalid falls back to the node default", map[string]string{PodStartupLatencyLabel: "soon"}, 2 * time.Second, 2 * time.Second},
+	}
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: c.labels}}
+		if actual := StartupLatency(pod, c.nodeDefault); actual != c.expected {
+			t.Fatalf("(case: %s) expected %s, got %s", c.desc, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Name of the informer index of pods by the node they are bound to.
+const nodeNameIndex = "nodeName"
+
+// NodeManager owns the fake nodes of a process. A single pod informer,
+// shared by all the nodes, watches the pods bound to any node and
+// dispatches them to their node by spec.nodeName, so that the API server
+// sees one watch however many nodes are simulated.
+type NodeManager interface {
+	// Start starts the pod informer and blocks until its cache is
+	// populated. Only the first call has any effect.
+	Start() error
+	// Add registers the node and starts simulating it.
+	Add(n FakeNode) error
+	// Delete stops simulating the node and deletes it, as if it was removed
+	// from the cluster. The pods bound to it are left as they are.
+	Delete(name string) error
+	// Nodes returns the nodes being simulated, ordered by name.
+	Nodes() []FakeNode
+	// WatchStats returns how often the pod watch was restarted.
+	WatchStats() WatchStats
+	// BoundPods lists the pods bound to any node, in any namespace, from
+	// the informer's cache, so that others need no watch of their own. It
+	// is up to date once Start returns.
+	BoundPods() corelisters.PodLister
+	// Stop shuts all the nodes down, failing their pods and deleting them,
+	// and then stops the informer.
+	Stop()
+}
+
+func NewNodeManager(client *kubernetes.Clientset) NodeManager {
+	// Only pods bound to a node
+	boundPods := func(options *metav1.ListOptions) {
+		options.FieldSelector = "spec.nodeName!="
+	}
+	return newNodeManager(client, &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			boundPods(&options)
+			return client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			boundPods(&options)
+			return client.CoreV1().Pods(metav1.NamespaceAll).Watch(options)
+		},
+	})
+}
+
+// Builds a node manager whose informer lists and watches the pods with
+// listWatch.
+func newNodeManager(client *kubernetes.Clientset, listWatch cache.ListerWatcher) *nodeManager {
+	counting := &countingListWatch{ListerWatcher: listWatch}
+	m := &nodeManager{
+		client:    client,
+		listWatch: counting,
+		pods: cache.NewSharedIndexInformer(counting, &v1.Pod{}, 0, cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+			nodeNameIndex: func(obj interface{}) ([]string, error) {
+				pod, ok := obj.(*v1.Pod)
+				if !ok {
+					return nil, nil
+				}
+				return []string{pod.Spec.NodeName}, nil
+			},
+		}),
+		nodes: map[string]FakeNode{},
+		stop:  make(chan struct{}),
+	}
+	m.pods.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				m.dispatch(pod, FakeNode.podAdded)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if pod, ok := newObj.(*v1.Pod); ok {
+				m.dispatch(pod, FakeNode.podModified)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				m.dispatch(pod, FakeNode.podDeleted)
+			}
+		},
+	})
+	return m
+}
+
+type nodeManager struct {
+	sync.RWMutex
+	client    *kubernetes.Clientset
+	listWatch *countingListWatch
+	pods      cache.SharedIndexInformer
+	nodes     map[string]FakeNode
+	stop      chan struct{}
+	startOnce sync.Once
+	startErr  error
+	stopOnce  sync.Once
+}
+
+func (m *nodeManager) Start() error {
+	m.startOnce.Do(func() {
+		go m.pods.Run(m.stop)
+		if !cache.WaitForCacheSync(m.stop, m.pods.HasSynced) {
+			m.startErr = fmt.Errorf("failed to sync the pod informer cache")
+			return
+		}
+		log.Debug("node manager pod cache synced")
+	})
+	return m.startErr
+}
+
+// Passes the pod to the node it is bound to, if the node is managed here.
+// Events are dispatched one at a time, so handlers must not block.
+func (m *nodeManager) dispatch(pod *v1.Pod, handle func(FakeNode, *v1.Pod)) {
+	m.RLock()
+	defer m.RUnlock()
+	if n, ok := m.nodes[pod.Spec.NodeName]; ok {
+		handle(n, pod)
+	}
+}
+
+func (m *nodeManager) Add(n FakeNode) error {
+	m.Lock()
+	if _, exists := m.nodes[n.Name()]; exists {
+		m.Unlock()
+		return fmt.Errorf("node %s already exists", n.Name())
+	}
+	m.nodes[n.Name()] = n
+	// Pods may already be bound to a node of the same name, e.g. by an
+	// earlier run. Events that are already in the cache but not yet
+	// dispatched are dispatched afterwards, which is harmless since adding
+	// and removing pods is idempotent.
+	pods, err := m.pods.GetIndexer().ByIndex(nodeNameIndex, n.Name())
+	if err != nil {
+		delete(m.nodes, n.Name())
+		m.Unlock()
+		return err
+	}
+	for _, obj := range pods {
+		n.podAdded(obj.(*v1.Pod))
+	}
+	m.Unlock()
+
+	if err := n.start(m.client); err != nil {
+		m.Lock()
+		delete(m.nodes, n.Name())
+		m.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *nodeManager) Delete(name string) error {
+	m.Lock()
+	n, ok := m.nodes[name]
+	delete(m.nodes, name)
+	m.Unlock()
+	if !ok {
+		return fmt.Errorf("node %s is not managed", name)
+	}
+	n.stop()
+	return n.unregister()
+}
+
+func (m *nodeManager) Nodes() []FakeNode {
+	m.RLock()
+	defer m.RUnlock()
+	nodes := []FakeNode{}
+	for _, n := range m.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name() < nodes[j].Name() })
+	return nodes
+}
+
+func (m *nodeManager) WatchStats() WatchStats {
+	return m.listWatch.watchStats()
+}
+
+func (m *nodeManager) BoundPods() corelisters.PodLister {
+	return corelisters.NewPodLister(m.pods.GetIndexer())
+}
+
+func (m *nodeManager) Stop() {
+	for _, n := range m.Nodes() {
+		log.WithFields(log.Fields{"node": n.Name()}).Debug("stopping node")
+		n.stop()
+		n.failPods()
+		if err := n.unregister(); err != nil {
+			log.WithFields(log.Fields{"node": n.Name(), "error": err.Error()}).Warning("failed to delete node")
+		}
+		m.Lock()
+		delete(m.nodes, n.Name())
+		m.Unlock()
+	}
+	m.stopOnce.Do(func() { close(m.stop) })
+}
@@ -0,0 +1,170 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/IntelAI/nodus/pkg/config"
+)
+
+// Records the pod events it receives.
+type recordingNode struct {
+	sync.Mutex
+	name   string
+	events []string
+}
+
+func (n *recordingNode) Name() string                             { return n.name }
+func (n *recordingNode) Class() string                            { return "" }
+func (n *recordingNode) start(client *kubernetes.Clientset) error { return nil }
+func (n *recordingNode) stop()                                    {}
+func (n *recordingNode) failPods()                                {}
+func (n *recordingNode) unregister() error                        { return nil }
+func (n *recordingNode) podAdded(pod *v1.Pod)                     { n.record("added", pod) }
+func (n *recordingNode) podModified(pod *v1.Pod)                  { n.record("modified", pod) }
+func (n *recordingNode) podDeleted(pod *v1.Pod)                   { n.record("deleted", pod) }
+
+func (n *recordingNode) record(event string, pod *v1.Pod) {
+	n.Lock()
+	defer n.Unlock()
+	n.events = append(n.events, event+" "+pod.Name)
+}
+
+func (n *recordingNode) recorded() []string {
+	n.Lock()
+	defer n.Unlock()
+	return append([]string(nil), n.events...)
+}
+
+func boundPod(name, nodeName string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+}
+
+func newTestManager(pods ...*v1.Pod) *nodeManager {
+	m := newNodeManager(nil, &cache.ListWatch{})
+	for _, pod := range pods {
+		m.pods.GetIndexer().Add(pod)
+	}
+	return m
+}
+
+func TestNodeManager(t *testing.T) {
+	cases := []struct {
+		desc     string
+		existing []*v1.Pod
+		events   func(m *nodeManager)
+		expected map[string][]string
+	}{
+		{
+			desc: "pods are dispatched to their node",
+			events: func(m *nodeManager) {
+				m.dispatch(boundPod("p1", "a"), FakeNode.podAdded)
+				m.dispatch(boundPod("p2", "b"), FakeNode.podAdded)
+				m.dispatch(boundPod("p1", "a"), FakeNode.podModified)
+				m.dispatch(boundPod("p1", "a"), FakeNode.podDeleted)
+			},
+			expected: map[string][]string{
+				"a": {"added p1", "modified p1", "deleted p1"},
+				"b": {"added p2"},
+			},
+		},
+		{
+			desc: "pods of other nodes are ignored",
+			events: func(m *nodeManager) {
+				m.dispatch(boundPod("p1", "c"), FakeNode.podAdded)
+			},
+			expected: map[string][]string{"a": nil, "b": nil},
+		},
+		{
+			desc:     "pods already bound are added with the node",
+			existing: []*v1.Pod{boundPod("p1", "a"), boundPod("p2", "c")},
+			events:   func(m *nodeManager) {},
+			expected: map[string][]string{"a": {"added p1"}, "b": nil},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		m := newTestManager(c.existing...)
+		nodes := map[string]*recordingNode{}
+		for _, name := range []string{"a", "b"} {
+			nodes[name] = &recordingNode{name: name}
+			if err := m.Add(nodes[name]); err != nil {
+				t.Fatalf("(case: %s) unexpected error adding node %s: %s", c.desc, name, err.Error())
+			}
+		}
+		c.events(m)
+		for name, expected := range c.expected {
+			if actual := nodes[name].recorded(); !reflect.DeepEqual(expected, actual) {
+				t.Fatalf("(case: %s) expected node %s to receive %v, got %v", c.desc, name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestNodeManager_Delete(t *testing.T) {
+	m := newTestManager()
+	for _, name := range []string{"b", "a"} {
+		if err := m.Add(&recordingNode{name: name}); err != nil {
+			t.Fatalf("unexpected error adding node %s: %s", name, err.Error())
+		}
+	}
+	if err := m.Add(&recordingNode{name: "a"}); err == nil {
+		t.Fatalf("expected an error adding node a twice")
+	}
+	if err := m.Delete("a"); err != nil {
+		t.Fatalf("unexpected error deleting node a: %s", err.Error())
+	}
+	if err := m.Delete("a"); err == nil {
+		t.Fatalf("expected an error deleting node a twice")
+	}
+	names := []string{}
+	for _, n := range m.Nodes() {
+		names = append(names, n.Name())
+	}
+	if !reflect.DeepEqual([]string{"b"}, names) {
+		t.Fatalf("expected nodes [b], got %v", names)
+	}
+}
+
+// Dispatches pod updates across many nodes with the default pod capacity of
+// 110 pods each, as the pod informer of a large simulation does. Each
+// update goes through the node manager to the node's pod set and transition
+// queue.
+func BenchmarkNodeManager_dispatch(b *testing.B) {
+	const podsPerNode = 110
+	for _, nodes := range []int{100, 1000, 2000} {
+		b.Run(fmt.Sprintf("nodes=%d", nodes), func(b *testing.B) {
+			m := newTestManager()
+			pods := make([]*v1.Pod, 0, nodes*podsPerNode)
+			for i := 0; i < nodes; i++ {
+				name := fmt.Sprintf("node-%d", i)
+				m.nodes[name] = NewFakeNode(name, "large", map[string]string{}, config.NodeResources{}, Heartbeat{}, 0)
+				for j := 0; j < podsPerNode; j++ {
+					pod := boundPod(fmt.Sprintf("%s-pod-%d", name, j), name)
+					pod.Status.Phase = v1.PodPending
+					if j%2 == 1 {
+						pod.Status.Phase = v1.PodRunning
+					}
+					m.dispatch(pod, FakeNode.podAdded)
+					pods = append(pods, pod)
+				}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.dispatch(pods[i%len(pods)], FakeNode.podModified)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"sync"
 
 	"k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type PodSet interface {
@@ -12,49 +11,55 @@ type PodSet interface {
 	Remove(pod *v1.Pod)
 	Update(pod *v1.Pod)
 	OfPhase(phases ...v1.PodPhase) []*v1.Pod
-	Expired() []*v1.Pod
+	Len() int
 }
 
 func NewPodSet() PodSet {
-	return &podset{}
+	return &podset{
+		pods:    map[string]*v1.Pod{},
+		byPhase: map[v1.PodPhase]map[string]*v1.Pod{},
+	}
 }
 
+// Pods are keyed by namespace/name and indexed by phase, so adding, updating
+// and removing a pod take constant time. When pods are due to change phase
+// is up to the node's transition queue.
 type podset struct {
 	sync.RWMutex
-	pods []*v1.Pod
+	pods    map[string]*v1.Pod
+	byPhase map[v1.PodPhase]map[string]*v1.Pod
 }
 
+// Adding a pod that is already in the set updates it.
 func (s *podset) Add(pod *v1.Pod) {
-	s.Lock()
-	defer s.Unlock()
-
-	s.pods = append(s.pods, pod)
+	s.Update(pod)
 }
 
 func (s *podset) Remove(pod *v1.Pod) {
 	s.Lock()
 	defer s.Unlock()
 
-	newPods := []*v1.Pod{}
-	for _, p := range s.pods {
-		if p.Name != pod.Name {
-			newPods = append(newPods, p)
-		}
+	key := podKey(pod)
+	if existing, ok := s.pods[key]; ok {
+		delete(s.byPhase[existing.Status.Phase], key)
+		delete(s.pods, key)
 	}
-	s.pods = newPods
 }
 
 func (s *podset) Update(pod *v1.Pod) {
 	s.Lock()
 	defer s.Unlock()
 
-	newPods := []*v1.Pod{pod}
-	for _, p := range s.pods {
-		if p.Name != pod.Name {
-			newPods = append(newPods, p)
-		}
+	key := podKey(pod)
+	if existing, ok := s.pods[key]; ok {
+		delete(s.byPhase[existing.Status.Phase], key)
+	}
+	s.pods[key] = pod
+	phase := pod.Status.Phase
+	if s.byPhase[phase] == nil {
+		s.byPhase[phase] = map[string]*v1.Pod{}
 	}
-	s.pods = newPods
+	s.byPhase[phase][key] = pod
 }
 
 func (s *podset) OfPhase(phases ...v1.PodPhase) []*v1.Pod {
@@ -62,42 +67,29 @@ func (s *podset) OfPhase(phases ...v1.PodPhase) []*v1.Pod {
 	defer s.RUnlock()
 
 	result := []*v1.Pod{}
-	for _, p := range s.pods {
-		for _, phase := range phases {
-			if p.Status.Phase == phase {
-				result = append(result, p)
-				break
-			}
+	for i, phase := range phases {
+		if seen(phases[:i], phase) {
+			continue
+		}
+		for _, p := range s.byPhase[phase] {
+			result = append(result, p)
 		}
 	}
 	return result
 }
 
-func (s *podset) Expired() []*v1.Pod {
-	running := s.OfPhase(v1.PodRunning)
+func seen(phases []v1.PodPhase, phase v1.PodPhase) bool {
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
 
+func (s *podset) Len() int {
 	s.RLock()
 	defer s.RUnlock()
 
-	expired := []*v1.Pod{}
-	for _, pod := range running {
-		// Compute elapsed wall time since pod started running
-		// using the lastTransitionTime of the PodRunning pod condition.
-		//
-		// Compare running time against the per-pod run duration
-		//
-		// If the pod has been in running phase longer than the
-		// desired duration, emit it in the result
-		for _, c := range pod.Status.Conditions {
-			if c.Type == v1.PodReady {
-				ready := c.LastTransitionTime
-				deadline := metav1.NewTime(ready.Add(RunDuration(pod)))
-				now := metav1.Now()
-				if deadline.Before(&now) {
-					expired = append(expired, pod)
-				}
-			}
-		}
-	}
-	return expired
+	return len(s.pods)
 }
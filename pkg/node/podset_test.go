@@ -0,0 +1,174 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A pod in the namespace and phase.
+func setPod(namespace, name string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func podKeys(pods []*v1.Pod) []string {
+	keys := []string{}
+	for _, pod := range pods {
+		keys = append(keys, podKey(pod))
+	}
+	return keys
+}
+
+func sortedKeys(pods []*v1.Pod) []string {
+	keys := podKeys(pods)
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPodSet(t *testing.T) {
+	cases := []struct {
+		desc     string
+		steps    func(s PodSet)
+		pending  []string
+		running  []string
+		expected int
+	}{
+		{
+			desc:     "empty",
+			steps:    func(s PodSet) {},
+			pending:  []string{},
+			running:  []string{},
+			expected: 0,
+		},
+		{
+			desc: "pods with the same name in different namespaces",
+			steps: func(s PodSet) {
+				s.Add(setPod("a", "p", v1.PodPending))
+				s.Add(setPod("b", "p", v1.PodPending))
+				s.Remove(setPod("a", "p", v1.PodPending))
+			},
+			pending:  []string{"b/p"},
+			running:  []string{},
+			expected: 1,
+		},
+		{
+			desc: "updates move pods between phases",
+			steps: func(s PodSet) {
+				s.Add(setPod("a", "p1", v1.PodPending))
+				s.Add(setPod("a", "p2", v1.PodPending))
+				s.Update(setPod("a", "p1", v1.PodRunning))
+			},
+			pending:  []string{"a/p2"},
+			running:  []string{"a/p1"},
+			expected: 2,
+		},
+		{
+			desc: "adding a pod twice updates it",
+			steps: func(s PodSet) {
+				s.Add(setPod("a", "p1", v1.PodPending))
+				s.Add(setPod("a", "p1", v1.PodRunning))
+			},
+			pending:  []string{},
+			running:  []string{"a/p1"},
+			expected: 1,
+		},
+		{
+			desc: "terminal and removed pods are not running",
+			steps: func(s PodSet) {
+				s.Add(setPod("a", "p1", v1.PodRunning))
+				s.Add(setPod("a", "p2", v1.PodRunning))
+				s.Add(setPod("a", "p3", v1.PodRunning))
+				s.Update(setPod("a", "p1", v1.PodSucceeded))
+				s.Remove(setPod("a", "p2", v1.PodRunning))
+			},
+			pending:  []string{},
+			running:  []string{"a/p3"},
+			expected: 2,
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		s := NewPodSet()
+		c.steps(s)
+		if actual := sortedKeys(s.OfPhase(v1.PodPending)); !reflect.DeepEqual(c.pending, actual) {
+			t.Fatalf("(case: %s) expected pending pods %v, got %v", c.desc, c.pending, actual)
+		}
+		if actual := sortedKeys(s.OfPhase(v1.PodRunning, v1.PodRunning)); !reflect.DeepEqual(c.running, actual) {
+			t.Fatalf("(case: %s) expected running pods %v, got %v", c.desc, c.running, actual)
+		}
+		if s.Len() != c.expected {
+			t.Fatalf("(case: %s) expected %d pods, got %d", c.desc, c.expected, s.Len())
+		}
+	}
+}
+
+// Sizes of the pod sets in the benchmarks: the default pod capacity of a
+// node, and larger nodes.
+var benchmarkSizes = []int{110, 1000, 10000}
+
+// A pod set of the size with half of the pods pending and half running.
+func benchmarkPodSet(size int) (PodSet, []*v1.Pod) {
+	s := NewPodSet()
+	pods := make([]*v1.Pod, 0, size)
+	for i := 0; i < size; i++ {
+		phase := v1.PodPending
+		if i%2 == 1 {
+			phase = v1.PodRunning
+		}
+		pod := setPod("default", fmt.Sprintf("pod-%d", i), phase)
+		s.Add(pod)
+		pods = append(pods, pod)
+	}
+	return s, pods
+}
+
+func BenchmarkPodSet_Update(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("pods=%d", size), func(b *testing.B) {
+			s, pods := benchmarkPodSet(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Update(pods[i%size])
+			}
+		})
+	}
+}
+
+// Removes every pod of the set in turn, refilling it once it is empty.
+func BenchmarkPodSet_Remove(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("pods=%d", size), func(b *testing.B) {
+			var s PodSet
+			var pods []*v1.Pod
+			for i := 0; i < b.N; i++ {
+				if i%size == 0 {
+					b.StopTimer()
+					s, pods = benchmarkPodSet(size)
+					b.StartTimer()
+				}
+				s.Remove(pods[i%size])
+			}
+		})
+	}
+}
+
+func BenchmarkPodSet_OfPhase(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("pods=%d", size), func(b *testing.B) {
+			s, _ := benchmarkPodSet(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.OfPhase(v1.PodPending)
+			}
+		})
+	}
+}
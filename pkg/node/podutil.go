@@ -8,6 +8,7 @@ import (
 
 const PodPhaseLabel = "np.terminalPhase"
 const PodDurationLabel = "np.runDuration"
+const PodStartupLatencyLabel = "np.startupLatency"
 
 // Returns the specified terminal phase as declared in a well-known pod label.
 // If left unset or the value does not match a known terminal phase, defaults
@@ -34,3 +35,30 @@ func RunDuration(pod *v1.Pod) time.Duration {
 	}
 	return d
 }
+
+// Returns how long the pod takes to start running once it is bound to a
+// node, as declared in a well-known pod label. If left unset or the value
+// cannot be parsed as a duration, defaults to the node's startup latency.
+func StartupLatency(pod *v1.Pod, nodeDefault time.Duration) time.Duration {
+	d, err := time.ParseDuration(pod.ObjectMeta.Labels[PodStartupLatencyLabel])
+	if err != nil || d < 0 {
+		return nodeDefault
+	}
+	return d
+}
+
+// Returns when the pod last became ready, and whether it is ready.
+func readySince(pod *v1.Pod) (time.Time, bool) {
+	since, ready := time.Time{}, false
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			since, ready = c.LastTransitionTime.Time, c.Status == v1.ConditionTrue
+		}
+	}
+	return since, ready
+}
+
+// Returns the namespace/name of the pod.
+func podKey(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
@@ -0,0 +1,64 @@
+package node
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WatchStats counts how often the pod watch of a NodeManager was restarted.
+// The informer restarts a watch that the API server closes, e.g. after a
+// timeout or a restart, from the last resource version it saw. If that
+// version has expired ("410 Gone") or the watch fails, it lists all pods
+// again and reconciles the pods of the nodes with the list, so that no pod
+// events are lost either way.
+type WatchStats struct {
+	// Watches restarted from the last resource version
+	Reconnects int64
+	// Lists of all pods after the first
+	Relists int64
+}
+
+// Counts and logs the lists and watches of the pod informer.
+type countingListWatch struct {
+	cache.ListerWatcher
+	sync.Mutex
+	listed bool
+	// Whether the last call was a list, which the next watch follows
+	afterList bool
+	stats     WatchStats
+}
+
+func (lw *countingListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	lw.Lock()
+	if lw.listed {
+		lw.stats.Relists++
+		log.WithFields(log.Fields{"relists": lw.stats.Relists}).Warning("relisting pods bound to fake nodes after the pod watch failed")
+	}
+	lw.listed = true
+	lw.afterList = true
+	lw.Unlock()
+	return lw.ListerWatcher.List(options)
+}
+
+// Watches that do not follow a list restart a watch that ended.
+func (lw *countingListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	lw.Lock()
+	if !lw.afterList {
+		lw.stats.Reconnects++
+		log.WithFields(log.Fields{"resource_version": options.ResourceVersion, "reconnects": lw.stats.Reconnects}).Info("reconnecting the pod watch")
+	}
+	lw.afterList = false
+	lw.Unlock()
+	return lw.ListerWatcher.Watch(options)
+}
+
+func (lw *countingListWatch) watchStats() WatchStats {
+	lw.Lock()
+	defer lw.Unlock()
+	return lw.stats
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func Test_countingListWatch(t *testing.T) {
+	cases := []struct {
+		desc string
+		// The calls of the informer, "list" or "watch"
+		calls    []string
+		expected WatchStats
+	}{
+		{
+			desc:     "first list and watch",
+			calls:    []string{"list", "watch"},
+			expected: WatchStats{},
+		},
+		{
+			desc:     "watch closed by the API server",
+			calls:    []string{"list", "watch", "watch", "watch"},
+			expected: WatchStats{Reconnects: 2},
+		},
+		{
+			desc:     "resource version expired",
+			calls:    []string{"list", "watch", "watch", "list", "watch"},
+			expected: WatchStats{Reconnects: 1, Relists: 1},
+		},
+		{
+			desc:     "list failed",
+			calls:    []string{"list", "list", "watch", "watch"},
+			expected: WatchStats{Reconnects: 1, Relists: 1},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		lw := &countingListWatch{ListerWatcher: &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return &v1.PodList{}, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return watch.NewEmptyWatch(), nil
+			},
+		}}
+		for _, call := range c.calls {
+			if call == "list" {
+				lw.List(metav1.ListOptions{})
+			} else {
+				lw.Watch(metav1.ListOptions{})
+			}
+		}
+		if actual := lw.watchStats(); actual != c.expected {
+			t.Fatalf("(case: %s) expected %+v, got %+v", c.desc, c.expected, actual)
+		}
+	}
+}
+
+func TestNodeManager_relist(t *testing.T) {
+	cases := []struct {
+		desc string
+		// Ends the first pod watch
+		end func(w *watch.FakeWatcher)
+	}{
+		{
+			// The informer takes a watch that ends before any event for a
+			// failure
+			desc: "watch closed before any event",
+			end:  func(w *watch.FakeWatcher) { w.Stop() },
+		},
+		{
+			desc: "resource version expired",
+			end: func(w *watch.FakeWatcher) {
+				w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonGone})
+			},
+		},
+	}
+
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		var lock sync.Mutex
+		list := &v1.PodList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items:    []v1.Pod{*boundPod("p1", "a"), *boundPod("p2", "a")},
+		}
+		watches := make(chan *watch.FakeWatcher, 10)
+		m := newNodeManager(nil, &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				lock.Lock()
+				defer lock.Unlock()
+				return list.DeepCopy(), nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				w := watch.NewFake()
+				watches <- w
+				return w, nil
+			},
+		})
+		n := &recordingNode{name: "a"}
+		if err := m.Add(n); err != nil {
+			t.Fatalf("(case: %s) unexpected error adding node a: %s", c.desc, err.Error())
+		}
+		if err := m.Start(); err != nil {
+			t.Fatalf("(case: %s) unexpected error starting: %s", c.desc, err.Error())
+		}
+
+		// p2 is deleted and p1 modified while the watch is down
+		lock.Lock()
+		modified := boundPod("p1", "a")
+		modified.Labels = map[string]string{PodPhaseLabel: string(v1.PodFailed)}
+		list = &v1.PodList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "3"},
+			Items:    []v1.Pod{*modified},
+		}
+		lock.Unlock()
+		c.end(<-watches)
+
+		expected := []string{"added p1", "added p2", "modified p1", "deleted p2"}
+		deadline := time.Now().Add(10 * time.Second)
+		for len(n.recorded()) < len(expected) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		m.Stop()
+		if actual := n.recorded(); !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("(case: %s) expected node a to receive %v, got %v", c.desc, expected, actual)
+		}
+		if actual := m.WatchStats(); actual.Relists != 1 {
+			t.Fatalf("(case: %s) expected 1 relist, got %+v", c.desc, actual)
+		}
+	}
+}
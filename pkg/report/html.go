@@ -0,0 +1,353 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+// Maximum number of pods drawn in the timeline, to keep reports of large runs
+// readable and small.
+const maxTimelinePods = 500
+
+type htmlReport struct {
+	Name        string
+	Start       string
+	Status      string
+	Summary     []htmlStat
+	Steps       []htmlStep
+	Timeline    template.HTML
+	CPU         template.HTML
+	Memory      template.HTML
+	PodsOmitted int
+}
+
+type htmlStat struct {
+	Label string
+	Value string
+}
+
+type htmlStep struct {
+	Name        string
+	Description string
+	Status      string
+	Duration    string
+	Error       string
+}
+
+// WriteHTML writes a self-contained HTML report of a scenario run: its steps,
+// a per-pod timeline, node utilization over time and summary statistics. The
+// report embeds all styles and charts, so it can be viewed offline.
+func WriteHTML(w io.Writer, result *exec.ScenarioResult) error {
+	report := htmlReport{
+		Name:    result.Name,
+		Start:   result.Start.Format(time.RFC1123),
+		Status:  "passed",
+		Summary: summarize(result),
+	}
+	if result.Failed() {
+		report.Status = "failed"
+	}
+	for _, step := range result.Steps {
+		s := htmlStep{
+			Name:        step.Name(),
+			Description: step.Description,
+			Status:      string(step.Status),
+			Duration:    round(step.Duration).String(),
+		}
+		if step.Err != nil {
+			s.Error = step.Err.Error()
+		}
+		report.Steps = append(report.Steps, s)
+	}
+
+	end := result.Start.Add(result.Duration)
+	pods := result.Pods
+	if len(pods) > maxTimelinePods {
+		report.PodsOmitted = len(pods) - maxTimelinePods
+		pods = pods[:maxTimelinePods]
+	}
+	report.Timeline = template.HTML(timelineSVG(pods, result.Start, end))
+	report.CPU = template.HTML(utilizationSVG(result.Utilization, result.Start, end, func(n exec.NodeUtilization) (int64, int64) {
+		return n.Allocated["cpu"], n.Allocatable["cpu"]
+	}))
+	report.Memory = template.HTML(utilizationSVG(result.Utilization, result.Start, end, func(n exec.NodeUtilization) (int64, int64) {
+		return n.Allocated["memory"], n.Allocatable["memory"]
+	}))
+
+	return htmlTemplate.Execute(w, report)
+}
+
+func WriteHTMLFile(path string, result *exec.ScenarioResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteHTML(f, result); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %s", path, err.Error())
+	}
+	return f.Close()
+}
+
+func summarize(result *exec.ScenarioResult) []htmlStat {
+	counts := map[exec.StepStatus]int{}
+	for _, step := range result.Steps {
+		counts[step.Status]++
+	}
+
+	metrics := result.Metrics
+	if metrics == nil {
+		metrics = exec.ComputeSchedulingMetrics(result.Pods)
+	}
+
+	stats := []htmlStat{
+		{"Duration", round(result.Duration).String()},
+		{"Steps", fmt.Sprintf("%d passed, %d failed, %d skipped", counts[exec.StepPassed], counts[exec.StepFailed], counts[exec.StepSkipped])},
+		{"Pods", fmt.Sprintf("%d created, %d scheduled, %d running", metrics.PodsCreated, metrics.PodsScheduled, metrics.PodsRunning)},
+		{"Scheduling latency", metrics.SchedulingLatency.String()},
+		{"Time to running", metrics.TimeToRunning.String()},
+		{"Binding throughput", fmt.Sprintf("%.2f pods/s, peak %.0f pods/s", metrics.Throughput, metrics.PeakThroughput)},
+	}
+
+	maxNodes := 0
+	var peakCPU, peakMemory float64
+	for _, sample := range result.Utilization {
+		if len(sample.Nodes) > maxNodes {
+			maxNodes = len(sample.Nodes)
+		}
+		cluster := sample.Cluster()
+		cpu, cpuAllocatable := cluster.Allocated["cpu"], cluster.Allocatable["cpu"]
+		memory, memoryAllocatable := cluster.Allocated["memory"], cluster.Allocatable["memory"]
+		if cpuAllocatable > 0 && float64(cpu)/float64(cpuAllocatable) > peakCPU {
+			peakCPU = float64(cpu) / float64(cpuAllocatable)
+		}
+		if memoryAllocatable > 0 && float64(memory)/float64(memoryAllocatable) > peakMemory {
+			peakMemory = float64(memory) / float64(memoryAllocatable)
+		}
+	}
+	stats = append(stats,
+		htmlStat{"Nodes", fmt.Sprintf("%d at most", maxNodes)},
+		htmlStat{"Peak CPU requested", fmt.Sprintf("%.1f%% of allocatable", peakCPU*100)},
+		htmlStat{"Peak memory requested", fmt.Sprintf("%.1f%% of allocatable", peakMemory*100)},
+	)
+	return stats
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
+
+// Chart geometry, in pixels.
+const (
+	chartLabelWidth = 200
+	chartWidth      = 760
+	timelineRow     = 16
+	chartHeight     = 200
+	axisHeight      = 24
+)
+
+var classColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// Maps a time to an x coordinate of a chart spanning start to end.
+func xOf(t, start, end time.Time) float64 {
+	span := end.Sub(start)
+	if span <= 0 {
+		return chartLabelWidth
+	}
+	offset := t.Sub(start)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > span {
+		offset = span
+	}
+	return chartLabelWidth + float64(chartWidth)*float64(offset)/float64(span)
+}
+
+func timeAxis(b *strings.Builder, y float64, start, end time.Time) {
+	fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="axis"/>`, chartLabelWidth, y, chartLabelWidth+chartWidth, y)
+	const ticks = 5
+	for i := 0; i <= ticks; i++ {
+		t := start.Add(end.Sub(start) * time.Duration(i) / ticks)
+		x := xOf(t, start, end)
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="axis"/>`, x, y, x, y+4)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, x, y+16, round(t.Sub(start)))
+	}
+}
+
+func timelineSVG(pods []*exec.PodTimeline, start, end time.Time) string {
+	if len(pods) == 0 {
+		return `<p class="empty">No pods were observed.</p>`
+	}
+	height := len(pods)*timelineRow + axisHeight
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, chartLabelWidth+chartWidth+10, height)
+	for i, pod := range pods {
+		y := float64(i * timelineRow)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartLabelWidth-6, y+12, html.EscapeString(pod.Name))
+
+		// Each stage lasts until the next one that was reached.
+		finished := pod.Finished
+		if finished.IsZero() {
+			finished = end
+		}
+		stages := []struct {
+			class string
+			from  time.Time
+		}{
+			{"pending", pod.Created},
+			{"scheduled", pod.Scheduled},
+			{"running", pod.Running},
+		}
+		for j, stage := range stages {
+			if stage.from.IsZero() {
+				continue
+			}
+			to := finished
+			for _, next := range stages[j+1:] {
+				if !next.from.IsZero() {
+					to = next.from
+					break
+				}
+			}
+			class := stage.class
+			if class == "running" && pod.Phase == "Failed" {
+				class = "failed"
+			}
+			x1, x2 := xOf(stage.from, start, end), xOf(to, start, end)
+			if x2-x1 < 1 {
+				x2 = x1 + 1
+			}
+			fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%d" class="%s"><title>%s: %s %s, %s</title></rect>`,
+				x1, y+2, x2-x1, timelineRow-4, class, html.EscapeString(pod.Name), class, round(stage.from.Sub(start)), round(to.Sub(stage.from)))
+		}
+	}
+	timeAxis(b, float64(len(pods)*timelineRow), start, end)
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// Draws requested / allocatable over time, for each node class and for the
+// whole cluster.
+func utilizationSVG(samples []*exec.UtilizationSample, start, end time.Time, metric func(exec.NodeUtilization) (int64, int64)) string {
+	if len(samples) == 0 {
+		return `<p class="empty">No utilization samples were taken.</p>`
+	}
+
+	classes := []string{}
+	seen := map[string]bool{}
+	for _, sample := range samples {
+		for _, n := range sample.Nodes {
+			if !seen[n.Class] {
+				seen[n.Class] = true
+				classes = append(classes, n.Class)
+			}
+		}
+	}
+	sort.Strings(classes)
+
+	series := map[string][]string{}
+	for _, sample := range samples {
+		requested, allocatable := map[string]int64{}, map[string]int64{}
+		for _, n := range sample.Nodes {
+			r, a := metric(n)
+			requested[n.Class] += r
+			allocatable[n.Class] += a
+			requested[""] += r
+			allocatable[""] += a
+		}
+		x := xOf(sample.Time, start, end)
+		for _, class := range append([]string{""}, classes...) {
+			ratio := 0.0
+			if allocatable[class] > 0 {
+				ratio = float64(requested[class]) / float64(allocatable[class])
+			}
+			if ratio > 1 {
+				ratio = 1
+			}
+			series[class] = append(series[class], fmt.Sprintf("%.1f,%.1f", x, chartHeight*(1-ratio)))
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, chartLabelWidth+chartWidth+10, chartHeight+axisHeight)
+	for _, percent := range []int{0, 50, 100} {
+		y := chartHeight * (1 - float64(percent)/100)
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`, chartLabelWidth, y, chartLabelWidth+chartWidth, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%d%%</text>`, chartLabelWidth-6, y+4, percent)
+	}
+	legend := 0
+	for i, class := range append([]string{""}, classes...) {
+		color, name, dash := "#000", "all nodes", ` stroke-dasharray="4 3"`
+		if class != "" {
+			color, name, dash = classColors[(i-1)%len(classColors)], class, ""
+		}
+		fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"%s/>`, strings.Join(series[class], " "), color, dash)
+		fmt.Fprintf(b, `<rect x="10" y="%d" width="12" height="3" fill="%s"/><text x="28" y="%d">%s</text>`, 20+legend*16, color, 25+legend*16, html.EscapeString(name))
+		legend++
+	}
+	timeAxis(b, chartHeight, start, end)
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - nodus report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 .passed, td.passed { color: #2e7d32; }
+h1 .failed, td.failed { color: #c62828; }
+td.skipped { color: #888; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { text-align: left; padding: 4px 10px; border-bottom: 1px solid #ddd; vertical-align: top; }
+td.error { font-family: monospace; white-space: pre-wrap; }
+svg { font-size: 11px; margin-bottom: 1em; }
+svg .axis, svg .grid { stroke: #aaa; }
+svg .grid { stroke-dasharray: 2 2; }
+svg .pending { fill: #f0ad4e; }
+svg .scheduled { fill: #5bc0de; }
+svg .running { fill: #5cb85c; }
+svg .failed { fill: #d9534f; }
+.legend span { display: inline-block; width: 12px; height: 12px; margin: 0 4px 0 12px; vertical-align: middle; }
+.empty { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}: <span class="{{.Status}}">{{.Status}}</span></h1>
+<p>Started {{.Start}}</p>
+
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+
+<h2>Steps</h2>
+<table>
+<tr><th>Step</th><th>Description</th><th>Status</th><th>Duration</th><th>Error</th></tr>
+{{range .Steps}}<tr><td>{{.Name}}</td><td>{{.Description}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Duration}}</td><td class="error">{{.Error}}</td></tr>
+{{end}}</table>
+
+<h2>Pod timeline</h2>
+<p class="legend"><span style="background:#f0ad4e"></span>created<span style="background:#5bc0de"></span>scheduled<span style="background:#5cb85c"></span>running<span style="background:#d9534f"></span>failed</p>
+{{.Timeline}}
+{{if .PodsOmitted}}<p class="empty">{{.PodsOmitted}} more pods are not shown.</p>{{end}}
+
+<h2>CPU requested / allocatable</h2>
+{{.CPU}}
+
+<h2>Memory requested / allocatable</h2>
+{{.Memory}}
+</body>
+</html>
+`))
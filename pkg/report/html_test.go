@@ -0,0 +1,78 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/IntelAI/nodus/pkg/config"
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+func Test_WriteHTML(t *testing.T) {
+	start := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(s float64) time.Time { return start.Add(time.Duration(s * float64(time.Second))) }
+	create := &config.Step{Verb: config.Create, Create: &config.CreateStep{Count: 2, Class: config.Class("1-cpu"), Object: config.Pod}}
+	assert := &config.Step{Verb: config.Assert, Assert: &config.AssertStep{Count: 2, Class: config.Class("1-cpu"), Object: config.Pod}}
+	result := &exec.ScenarioResult{
+		Name:     "html <test>",
+		Start:    start,
+		Duration: 10 * time.Second,
+		Steps: []*exec.StepResult{
+			{Index: 0, Description: "create 2 1-cpu pods", Step: create, Status: exec.StepPassed, Duration: time.Second},
+			{Index: 1, Description: "assert 2 1-cpu pods", Step: assert, Status: exec.StepFailed, Duration: time.Second, Err: fmt.Errorf("found 1 pods <of> class 1-cpu")},
+		},
+		Pods: []*exec.PodTimeline{
+			{Name: "pod-<1>", Class: "1-cpu", Created: at(1), Scheduled: at(2), Running: at(3), Finished: at(8), Phase: "Succeeded"},
+			{Name: "pod-2", Class: "1-cpu", Created: at(1), Scheduled: at(4)},
+		},
+		Utilization: []*exec.UtilizationSample{
+			{Time: at(0), Nodes: []exec.NodeUtilization{{Name: "n1", Class: "large",
+				Allocated:   exec.ResourceList{"cpu": 0, "memory": 0},
+				Allocatable: exec.ResourceList{"cpu": 4000, "memory": 1 << 30},
+			}}},
+			{Time: at(5), Nodes: []exec.NodeUtilization{{Name: "n1", Class: "large",
+				Allocated:   exec.ResourceList{"cpu": 3000, "memory": 1 << 28},
+				Allocatable: exec.ResourceList{"cpu": 4000, "memory": 1 << 30},
+			}}},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteHTML(buf, result); err != nil {
+		t.Fatalf("expected err to be nil, but got: %s", err)
+	}
+	report := buf.String()
+
+	cases := []struct {
+		desc     string
+		contains string
+	}{
+		{desc: "escaped title and status", contains: `<h1>html &lt;test&gt;: <span class="failed">failed</span></h1>`},
+		{desc: "step row", contains: `<td>step [2]</td><td>assert 2 1-cpu pods</td><td class="failed">failed</td><td>1s</td><td class="error">found 1 pods &lt;of&gt; class 1-cpu</td>`},
+		{desc: "step counts", contains: `<th>Steps</th><td>1 passed, 1 failed, 0 skipped</td>`},
+		{desc: "pod counts", contains: `<th>Pods</th><td>2 created, 2 scheduled, 1 running</td>`},
+		{desc: "scheduling latency", contains: `<th>Scheduling latency</th><td>p50 1s, p90 3s, p99 3s, max 3s (2 pods)</td>`},
+		{desc: "peak cpu", contains: `<th>Peak CPU requested</th><td>75.0% of allocatable</td>`},
+		{desc: "escaped pod name", contains: `>pod-&lt;1&gt;</text>`},
+		{desc: "running segment from 3s to 8s", contains: `<rect x="428.0" y="2.0" width="380.0" height="12" class="running">`},
+		{desc: "unfinished pod is scheduled until the end", contains: `<rect x="504.0" y="18.0" width="456.0" height="12" class="scheduled">`},
+		{desc: "cpu utilization of the class", contains: `<polyline points="200.0,200.0 580.0,50.0" fill="none" stroke="#1f77b4"`},
+	}
+	for _, c := range cases {
+		log.WithFields(log.Fields{"description": c.desc}).Infof("Running test")
+		if !strings.Contains(report, c.contains) {
+			t.Fatalf("(case: %s) expected the report to contain:\n%s\nbut got:\n%s", c.desc, c.contains, report)
+		}
+	}
+
+	for _, external := range []string{"<script", "<link", "src=", "url("} {
+		if strings.Contains(report, external) {
+			t.Fatalf("expected a self-contained report, but found `%s`", external)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, with a testsuite per scenario
+// and a testcase per step.
+func WriteJUnit(w io.Writer, results ...*exec.ScenarioResult) error {
+	suites := junitTestSuites{}
+	for _, result := range results {
+		suites.Suites = append(suites.Suites, junitSuite(result))
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func WriteJUnitFile(path string, results ...*exec.ScenarioResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteJUnit(f, results...); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %s", path, err.Error())
+	}
+	return f.Close()
+}
+
+func junitSuite(result *exec.ScenarioResult) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      result.Name,
+		Time:      seconds(result.Duration),
+		Timestamp: result.Start.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, step := range result.Steps {
+		section := step.Section
+		if section == "" {
+			section = "steps"
+		}
+		testCase := junitTestCase{
+			Name:      fmt.Sprintf("%s: %s", step.Name(), step.Description),
+			ClassName: fmt.Sprintf("%s.%s", result.Name, section),
+			Time:      seconds(step.Duration),
+			SystemOut: strings.Join(step.Logs, "\n"),
+		}
+		switch step.Status {
+		case exec.StepFailed:
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: step.Err.Error(),
+				Type:    string(step.Step.Verb),
+				Text:    step.Err.Error(),
+			}
+		case exec.StepSkipped:
+			suite.Skipped++
+			testCase.Skipped = &struct{}{}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+	return suite
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/config"
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+func Test_WriteJUnit(t *testing.T) {
+	create := &config.Step{Verb: config.Create, Create: &config.CreateStep{Count: 1, Class: config.Class("large"), Object: config.Node}}
+	assert := &config.Step{Verb: config.Assert, Assert: &config.AssertStep{Count: 1, Class: config.Class("large"), Object: config.Node}}
+	result := &exec.ScenarioResult{
+		Name:     "junit <test>",
+		Start:    time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC),
+		Duration: 3500 * time.Millisecond,
+		Steps: []*exec.StepResult{
+			{Section: "setup", Index: 0, Description: "create 1 large node", Step: create, Status: exec.StepPassed, Duration: time.Second, Logs: []string{"level=info msg=\"created\""}},
+			{Index: 0, Description: "assert 1 large node", Step: assert, Status: exec.StepFailed, Duration: 2500 * time.Millisecond, Err: fmt.Errorf("found 0 nodes of class large, but 1 expected")},
+			{Index: 1, Description: "assert 1 large node", Step: assert, Status: exec.StepSkipped},
+		},
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="junit &lt;test&gt;" tests="3" failures="1" skipped="1" time="3.500" timestamp="2019-03-01T12:00:00">
+    <testcase name="setup step [1]: create 1 large node" classname="junit &lt;test&gt;.setup" time="1.000">
+      <system-out>level=info msg=&#34;created&#34;</system-out>
+    </testcase>
+    <testcase name="step [1]: assert 1 large node" classname="junit &lt;test&gt;.steps" time="2.500">
+      <failure message="found 0 nodes of class large, but 1 expected" type="assert">found 0 nodes of class large, but 1 expected</failure>
+    </testcase>
+    <testcase name="step [2]: assert 1 large node" classname="junit &lt;test&gt;.steps" time="0.000">
+      <skipped></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	buf := &bytes.Buffer{}
+	if err := WriteJUnit(buf, result); err != nil {
+		t.Fatalf("expected err to be nil, but got: %s", err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
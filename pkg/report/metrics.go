@@ -0,0 +1,46 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+type metricsReport struct {
+	Scenario        string                  `json:"scenario"`
+	Start           time.Time               `json:"start"`
+	DurationSeconds float64                 `json:"durationSeconds"`
+	Metrics         *exec.SchedulingMetrics `json:"metrics"`
+}
+
+// WriteMetrics writes the scheduling metrics of a run as JSON.
+func WriteMetrics(w io.Writer, result *exec.ScenarioResult) error {
+	metrics := result.Metrics
+	if metrics == nil {
+		metrics = exec.ComputeSchedulingMetrics(result.Pods)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(metricsReport{
+		Scenario:        result.Name,
+		Start:           result.Start,
+		DurationSeconds: result.Duration.Seconds(),
+		Metrics:         metrics,
+	})
+}
+
+func WriteMetricsFile(path string, result *exec.ScenarioResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteMetrics(f, result); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %s", path, err.Error())
+	}
+	return f.Close()
+}
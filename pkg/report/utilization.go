@@ -0,0 +1,108 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+type utilizationReport struct {
+	Scenario string              `json:"scenario"`
+	Start    time.Time           `json:"start"`
+	Samples  []utilizationSample `json:"samples"`
+}
+
+type utilizationSample struct {
+	Time    time.Time               `json:"time"`
+	Nodes   []exec.NodeUtilization  `json:"nodes"`
+	Classes []exec.GroupUtilization `json:"classes"`
+	Cluster exec.GroupUtilization   `json:"cluster"`
+}
+
+// WriteUtilizationJSON writes the node utilization timeline of a run as
+// JSON, with the totals and fragmentation of each class and of the cluster.
+func WriteUtilizationJSON(w io.Writer, result *exec.ScenarioResult) error {
+	out := utilizationReport{Scenario: result.Name, Start: result.Start, Samples: []utilizationSample{}}
+	for _, sample := range result.Utilization {
+		out.Samples = append(out.Samples, utilizationSample{
+			Time:    sample.Time,
+			Nodes:   sample.Nodes,
+			Classes: sample.Classes(),
+			Cluster: sample.Cluster(),
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// WriteUtilizationCSV writes the node utilization timeline of a run as CSV,
+// with a row per resource of each node, class and the cluster at every
+// sample. The largestFree and stranded columns are empty for nodes.
+func WriteUtilizationCSV(w io.Writer, result *exec.ScenarioResult) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"time", "scope", "name", "resource", "allocated", "allocatable", "largestFree", "stranded"})
+	for _, sample := range result.Utilization {
+		at := strconv.FormatFloat(sample.Time.Sub(result.Start).Seconds(), 'f', 3, 64)
+		for _, n := range sample.Nodes {
+			for _, resource := range resourceNames(n.Allocatable) {
+				out.Write([]string{at, "node", n.Name, resource,
+					amount(n.Allocated[resource]), amount(n.Allocatable[resource]), "", ""})
+			}
+		}
+		groups := append(sample.Classes(), sample.Cluster())
+		for i, g := range groups {
+			scope := "class"
+			if i == len(groups)-1 {
+				scope = "cluster"
+			}
+			for _, resource := range resourceNames(g.Allocatable) {
+				out.Write([]string{at, scope, g.Class, resource,
+					amount(g.Allocated[resource]), amount(g.Allocatable[resource]),
+					amount(g.LargestFree[resource]), amount(g.Stranded[resource])})
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteUtilizationFile writes CSV if the path ends in .csv, and JSON
+// otherwise.
+func WriteUtilizationFile(path string, result *exec.ScenarioResult) error {
+	write := WriteUtilizationJSON
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		write = WriteUtilizationCSV
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, result); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %s", path, err.Error())
+	}
+	return f.Close()
+}
+
+func resourceNames(resources exec.ResourceList) []string {
+	names := []string{}
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func amount(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
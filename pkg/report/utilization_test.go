@@ -0,0 +1,45 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/IntelAI/nodus/pkg/exec"
+)
+
+func Test_WriteUtilizationCSV(t *testing.T) {
+	start := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	result := &exec.ScenarioResult{
+		Name:  "utilization",
+		Start: start,
+		Utilization: []*exec.UtilizationSample{
+			{Time: start.Add(1500 * time.Millisecond), Nodes: []exec.NodeUtilization{
+				{Name: "n1", Class: "small",
+					Allocated:   exec.ResourceList{"cpu": 2000, "pods": 2},
+					Allocatable: exec.ResourceList{"cpu": 2000, "pods": 10}},
+				{Name: "n2", Class: "small",
+					Allocated:   exec.ResourceList{"cpu": 500, "pods": 1},
+					Allocatable: exec.ResourceList{"cpu": 2000, "pods": 10}},
+			}},
+		},
+	}
+	expected := `time,scope,name,resource,allocated,allocatable,largestFree,stranded
+1.500,node,n1,cpu,2000,2000,,
+1.500,node,n1,pods,2,10,,
+1.500,node,n2,cpu,500,2000,,
+1.500,node,n2,pods,1,10,,
+1.500,class,small,cpu,2500,4000,1500,0
+1.500,class,small,pods,3,20,9,8
+1.500,cluster,,cpu,2500,4000,1500,0
+1.500,cluster,,pods,3,20,9,8
+`
+
+	var out bytes.Buffer
+	if err := WriteUtilizationCSV(&out, result); err != nil {
+		t.Fatalf("failed to write csv: %s", err.Error())
+	}
+	if out.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}